@@ -4,6 +4,7 @@ go 1.23.4
 
 require (
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/text v0.21.0
 	tinygo.org/x/go-llvm v0.0.0-20240804145059-aaff3eb751f0
 )
 
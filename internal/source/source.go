@@ -29,6 +29,12 @@ func FromFile(sourcePath string) (*SourceFile, error) {
 	return &SourceFile{source: contents, path: sourcePath}, nil
 }
 
+// Bytes returns the whole source contents, the returned
+// slice is shared with the source and must not be modified.
+func (s *SourceFile) Bytes() []byte {
+	return s.source
+}
+
 func (s *SourceFile) Read(p []byte) (n int, err error) {
 	n = copy(p, s.source[s.cursorAt:])
 	s.cursorAt += uint(n)
@@ -0,0 +1,234 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/EclesioMeloJunior/lotus/parser"
+	"tinygo.org/x/go-llvm"
+)
+
+// indexOutOfRange and sliceOutOfRange are the messages written to the
+// standard error before trapping on an out of range access.
+const (
+	indexOutOfRange = "panic: index out of range [%lld] with length %lld at line %d, column %d\n"
+	sliceOutOfRange = "panic: slice bounds out of range [%lld..%lld] with length %lld at line %d, column %d\n"
+)
+
+// arrayType returns the LLVM type of an array or slice type. Arrays are
+// LLVM arrays of their elements, slices are the length and a pointer to
+// the first element, laid out like %String.
+func (gen *IRGenerator) arrayType(rawType parser.Type) llvm.Type {
+	array := gen.types.Array(rawType)
+	elem := gen.memoryType(array.Elem)
+	if array.Slice {
+		return gen.context.StructType([]llvm.Type{gen.context.Int32Type(), llvm.PointerType(elem, 0)}, false)
+	}
+	return llvm.ArrayType(elem, int(array.Len))
+}
+
+// generateArrayLiteral builds the array value element by element, the
+// value is a constant when every element is.
+func (gen *IRGenerator) generateArrayLiteral(expr *parser.ArrayLiteral, fnName string) llvm.Value {
+	elemType := gen.types.Array(expr.Type).Elem
+
+	value := llvm.Undef(gen.arrayType(expr.Type))
+	for idx, elem := range expr.Elements {
+		elemValue := gen.toMemory(elemType, gen.generateExpression(elem, fnName))
+		value = gen.builder.CreateInsertValue(value, elemValue, idx, "")
+	}
+	return value
+}
+
+// generateIndexExpression loads the element from the array or slice.
+func (gen *IRGenerator) generateIndexExpression(expr *parser.IndexExpression, fnName string) llvm.Value {
+	return gen.load(expr.Type, gen.elementPointer(expr, fnName), "elem")
+}
+
+// generateAssignIndexStatement stores the value in the element of the
+// array or slice, top-level assignments run in the static initializer.
+func (gen *IRGenerator) generateAssignIndexStatement(stmt *parser.AssignIndexStatement, fnName string) {
+	if fnName == "" {
+		gen.staticInit(func() {
+			gen.store(stmt.Target.Type, gen.generateExpression(stmt.Value, staticInitName), gen.elementPointer(stmt.Target, staticInitName))
+		})
+		return
+	}
+
+	gen.store(stmt.Target.Type, gen.generateExpression(stmt.Value, fnName), gen.elementPointer(stmt.Target, fnName))
+}
+
+// elementPointer returns the address of the element, arrays are
+// indexed in place and slices through their pointer. The index is
+// checked against the length unless it is an integer literal, literal
+// indexes of arrays were checked by the parser.
+func (gen *IRGenerator) elementPointer(expr *parser.IndexExpression, fnName string) llvm.Value {
+	i64 := gen.context.Int64Type()
+	arrayType := parser.TypeOf(expr.Value)
+	array := gen.types.Array(arrayType)
+
+	if !array.Slice {
+		base := gen.addressOf(expr.Value, fnName)
+		index := gen.index(expr.Index, fnName)
+		if !checkedIndex(expr.Index) {
+			gen.checkIndex(index, llvm.ConstInt(i64, uint64(array.Len), false), expr.Line, expr.Column)
+		}
+
+		zero := llvm.ConstInt(i64, 0, false)
+		return gen.builder.CreateInBoundsGEP(gen.arrayType(arrayType), base, []llvm.Value{zero, index}, "elem.ptr")
+	}
+
+	length, data := gen.sliceParts(gen.generateExpression(expr.Value, fnName))
+	index := gen.index(expr.Index, fnName)
+	gen.checkIndex(index, length, expr.Line, expr.Column)
+	return gen.builder.CreateInBoundsGEP(gen.memoryType(array.Elem), data, []llvm.Value{index}, "elem.ptr")
+}
+
+// checkedIndex reports whether the range of an index or a slice bound
+// of an array was checked by the parser, other constants such as
+// len(a) are still checked at runtime.
+func checkedIndex(index parser.Expression) bool {
+	if index == nil {
+		return true
+	}
+
+	_, ok := index.(*parser.IntegerLiteral)
+	return ok
+}
+
+// generateSliceExpression builds the slice from the bounds of the array
+// or slice, the bounds must hold low <= high <= length.
+func (gen *IRGenerator) generateSliceExpression(expr *parser.SliceExpression, fnName string) llvm.Value {
+	i64 := gen.context.Int64Type()
+	arrayType := parser.TypeOf(expr.Value)
+	array := gen.types.Array(arrayType)
+
+	var length, data llvm.Value
+	if array.Slice {
+		length, data = gen.sliceParts(gen.generateExpression(expr.Value, fnName))
+	} else {
+		zero := llvm.ConstInt(i64, 0, false)
+		base := gen.addressOf(expr.Value, fnName)
+		length = llvm.ConstInt(i64, uint64(array.Len), false)
+		data = gen.builder.CreateInBoundsGEP(gen.arrayType(arrayType), base, []llvm.Value{zero, zero}, "data")
+	}
+
+	low := llvm.ConstInt(i64, 0, false)
+	if expr.Low != nil {
+		low = gen.index(expr.Low, fnName)
+	}
+
+	high := length
+	if expr.High != nil {
+		high = gen.index(expr.High, fnName)
+	}
+
+	if gen.boundsChecks && !(!array.Slice && checkedIndex(expr.Low) && checkedIndex(expr.High)) {
+		// negative bounds are huge unsigned values, greater than the length
+		inRange := gen.builder.CreateAnd(
+			gen.builder.CreateICmp(llvm.IntULE, low, high, ""),
+			gen.builder.CreateICmp(llvm.IntULE, high, length, ""),
+			"inbounds",
+		)
+		gen.trapUnless(inRange, "bounds",
+			gen.globalString("lotus.slice.oob", sliceOutOfRange),
+			low, high, length, gen.position(expr.Line), gen.position(expr.Column),
+		)
+	}
+
+	i32 := gen.context.Int32Type()
+	slice := llvm.Undef(gen.arrayType(expr.Type))
+	slice = gen.builder.CreateInsertValue(slice, gen.builder.CreateTrunc(gen.builder.CreateSub(high, low, ""), i32, "len"), 0, "")
+	slice = gen.builder.CreateInsertValue(slice, gen.builder.CreateInBoundsGEP(gen.memoryType(array.Elem), data, []llvm.Value{low}, "data"), 1, "")
+	return slice
+}
+
+// generateLenExpression returns the length of an array, a slice or a
+// string as an int32. The length of an array is known at compile time,
+// so its value is not evaluated.
+func (gen *IRGenerator) generateLenExpression(expr *parser.LenExpression, fnName string) llvm.Value {
+	i32 := gen.context.Int32Type()
+
+	if array := gen.types.Array(parser.TypeOf(expr.Value)); array != nil && !array.Slice {
+		return llvm.ConstInt(i32, uint64(array.Len), false)
+	}
+
+	if literal, ok := expr.Value.(*parser.StringLiteral); ok {
+		return llvm.ConstInt(i32, uint64(len(literal.Value)), false)
+	}
+
+	// slices and strings start with their length
+	return gen.builder.CreateExtractValue(gen.generateExpression(expr.Value, fnName), 0, "len")
+}
+
+// sliceParts returns the length of the slice, extended to 64
+// bits, and the pointer to its first element.
+func (gen *IRGenerator) sliceParts(slice llvm.Value) (llvm.Value, llvm.Value) {
+	length := gen.builder.CreateExtractValue(slice, 0, "")
+	length = gen.builder.CreateZExt(length, gen.context.Int64Type(), "len")
+	return length, gen.builder.CreateExtractValue(slice, 1, "data")
+}
+
+// index generates an index or a slice bound, extended to 64 bits by its
+// sign so negative indexes are out of range once compared unsigned.
+func (gen *IRGenerator) index(expr parser.Expression, fnName string) llvm.Value {
+	return gen.convert(gen.generateExpression(expr, fnName), parser.TypeOf(expr), parser.Int64)
+}
+
+// checkIndex traps with the position of the access when index is not
+// in [0, length), unless bounds checks are disabled.
+func (gen *IRGenerator) checkIndex(index, length llvm.Value, line, column int) {
+	if !gen.boundsChecks {
+		return
+	}
+
+	inRange := gen.builder.CreateICmp(llvm.IntULT, index, length, "inbounds")
+	gen.trapUnless(inRange, "bounds",
+		gen.globalString("lotus.index.oob", indexOutOfRange),
+		index, length, gen.position(line), gen.position(column),
+	)
+}
+
+func (gen *IRGenerator) position(value int) llvm.Value {
+	return llvm.ConstInt(gen.context.Int32Type(), uint64(value), false)
+}
+
+// globalString returns a pointer to a private null terminated string
+// with the given name, created on first use.
+func (gen *IRGenerator) globalString(name, value string) llvm.Value {
+	global := gen.Module.NamedGlobal(name)
+	if global.IsNil() {
+		init := gen.context.ConstString(value, true)
+		global = llvm.AddGlobal(gen.Module, init.Type(), name)
+		global.SetInitializer(init)
+		global.SetGlobalConstant(true)
+		global.SetLinkage(llvm.PrivateLinkage)
+		global.SetUnnamedAddr(true)
+	}
+
+	zero := llvm.ConstInt(gen.context.Int64Type(), 0, false)
+	return llvm.ConstInBoundsGEP(global.GlobalValueType(), global, []llvm.Value{zero, zero})
+}
+
+// addressOf returns the address of the value of expr, variables, fields
+// and elements are accessed in place, other values are copied to the
+// stack first. Only indexing reads from such a copy, the parser rejects
+// slices of arrays that are not stored in a variable.
+func (gen *IRGenerator) addressOf(expr parser.Expression, fnName string) llvm.Value {
+	switch expr := expr.(type) {
+	case *parser.Identifier:
+		ptr, ok := gen.lookupVar(expr.Value)
+		if !ok {
+			panic(fmt.Sprintf("variable not found: %s", expr.Value))
+		}
+		return ptr
+	case *parser.FieldAccess:
+		return gen.fieldPointer(expr, fnName)
+	case *parser.IndexExpression:
+		return gen.elementPointer(expr, fnName)
+	default:
+		rawType := parser.TypeOf(expr)
+		alloca := gen.createEntryAlloca(gen.memoryType(rawType), "tmp")
+		gen.store(rawType, gen.generateExpression(expr, fnName), alloca)
+		return alloca
+	}
+}
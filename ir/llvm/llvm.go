@@ -2,6 +2,7 @@ package llvm
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/EclesioMeloJunior/lotus/parser"
 	"tinygo.org/x/go-llvm"
@@ -18,9 +19,42 @@ type IRGenerator struct {
 	builder llvm.Builder
 	context llvm.Context
 
+	// targetData sizes the pointer sized integers
+	targetData llvm.TargetData
+
 	globals map[string]llvm.Value
 	fns     map[string]*Fn
-	locals  map[string]map[string]llvm.Value
+
+	// types describes the struct and array types of the program,
+	// structs holds the LLVM types of the structs in use
+	types   *parser.Types
+	structs map[parser.Type]llvm.Type
+
+	// boundsChecks traps on out of range indexes and slice bounds
+	boundsChecks bool
+
+	// scopes holds the local variables of the current function
+	// by block, innermost last, mirroring the parser scopes
+	scopes []map[string]llvm.Value
+
+	// loops holds the enclosing loops of the current
+	// statement, innermost last
+	loops []loop
+
+	// initBlock is where the static initializer continues,
+	// it is nil until a global needs it
+	initBlock llvm.BasicBlock
+}
+
+// staticInitName names the function computing the globals initial
+// values that are not constant, it runs before main.
+const staticInitName = "lotus.init"
+
+// loop holds the blocks break and continue jump to.
+type loop struct {
+	label         string
+	continueBlock llvm.BasicBlock
+	breakBlock    llvm.BasicBlock
 }
 
 // NewIRGenerator creates a new instance of IRGenerator.
@@ -29,27 +63,69 @@ func NewIRGenerator() *IRGenerator {
 	module := context.NewModule("main")
 	builder := context.NewBuilder()
 	return &IRGenerator{
-		Module:  module,
-		builder: builder,
-		context: context,
-		globals: make(map[string]llvm.Value),
-		locals:  make(map[string]map[string]llvm.Value),
-		fns:     make(map[string]*Fn),
+		Module:     module,
+		builder:    builder,
+		context:    context,
+		targetData: llvm.NewTargetData(module.DataLayout()),
+		globals:    make(map[string]llvm.Value),
+		fns:        make(map[string]*Fn),
+		structs:    make(map[parser.Type]llvm.Type),
+
+		boundsChecks: true,
 	}
 }
 
+// SetTarget generates the module for the machine target, pointer sized
+// integers take its pointer width. It must be called before GenerateIR.
+func (gen *IRGenerator) SetTarget(machine llvm.TargetMachine) {
+	gen.targetData = machine.CreateTargetData()
+	gen.Module.SetTarget(machine.Triple())
+	gen.Module.SetDataLayout(gen.targetData.String())
+}
+
+// DisableBoundsChecks stops checking indexes and slice bounds at
+// runtime, for hot loops whose accesses are known to be in range. Out
+// of range accesses are then undefined behavior. It must be called
+// before GenerateIR.
+func (gen *IRGenerator) DisableBoundsChecks() {
+	gen.boundsChecks = false
+}
+
 // GenerateIR generates LLVM IR from the given AST.
 func (gen *IRGenerator) GenerateIR(program *parser.Program) {
+	gen.types = program.Types
 	gen.generate(program.Statements, "")
+	gen.finishStaticInit()
 }
 
 func (gen *IRGenerator) generate(stmts []parser.Node, fnName string) {
 	for _, stmt := range stmts {
+		// statements after a return are unreachable
+		if fnName != "" && gen.blockTerminated() {
+			return
+		}
+
 		switch stmt := stmt.(type) {
 		case *parser.VarStatement:
 			gen.generateVarStatement(stmt, fnName)
 		case *parser.ReassignVarStatement:
-			gen.generateVarStatement(stmt.ToVarAssignment(), fnName)
+			gen.generateReassignStatement(stmt, fnName)
+		case *parser.StructStatement:
+			gen.structType(stmt.Type)
+		case *parser.AssignFieldStatement:
+			gen.generateAssignFieldStatement(stmt, fnName)
+		case *parser.AssignIndexStatement:
+			gen.generateAssignIndexStatement(stmt, fnName)
+		case *parser.IfStatement:
+			gen.generateIfStatement(stmt, fnName)
+		case *parser.WhileStatement:
+			gen.generateWhileStatement(stmt, fnName)
+		case *parser.ForStatement:
+			gen.generateForStatement(stmt, fnName)
+		case *parser.BreakStatement:
+			gen.builder.CreateBr(gen.findLoop(stmt.Label).breakBlock)
+		case *parser.ContinueStatement:
+			gen.builder.CreateBr(gen.findLoop(stmt.Label).continueBlock)
 		case *parser.FnStatement:
 			if fnName != "" {
 				panic("functions cannot be inside other functions")
@@ -58,44 +134,260 @@ func (gen *IRGenerator) generate(stmts []parser.Node, fnName string) {
 			gen.generateFnStatement(stmt)
 		case *parser.ReturnStatement:
 			gen.generateReturnStatement(stmt, fnName)
+		case *parser.FnCall:
+			gen.generateCallStatement(stmt, fnName)
 		}
 	}
 }
 
+// generateCallStatement calls the function for its side effects, the
+// result is discarded. Top-level calls run in the static initializer.
+func (gen *IRGenerator) generateCallStatement(stmt *parser.FnCall, fnName string) {
+	if fnName == "" {
+		gen.staticInit(func() {
+			gen.generateExpression(stmt, staticInitName)
+		})
+		return
+	}
+
+	gen.generateExpression(stmt, fnName)
+}
+
 // generateVarStatement generates LLVM IR for a variable declaration.
 func (gen *IRGenerator) generateVarStatement(stmt *parser.VarStatement, fnName string) {
-	var alloca llvm.Value
+	// constants are replaced by their value wherever they are used
+	if stmt.Const {
+		return
+	}
+
+	if fnName == "" {
+		gen.generateGlobalVarStatement(stmt)
+		return
+	}
+
+	allocaType := gen.context.Int8Type()
 	if stmt.Type != parser.Void {
-		alloca = gen.builder.CreateAlloca(gen.fromRawTypeToLLVMType(stmt.Type), stmt.Name)
-	} else {
-		alloca = gen.builder.CreateAlloca(gen.context.Int8Type(), stmt.Name)
+		allocaType = gen.memoryType(stmt.Type)
 	}
 
+	alloca := gen.createEntryAlloca(allocaType, stmt.Name)
 	if stmt.Value != nil {
 		varValue := gen.generateExpression(stmt.Value, fnName)
-		gen.builder.CreateStore(varValue, alloca)
+		gen.store(stmt.Type, varValue, alloca)
+	}
+
+	gen.declareVar(stmt.Name, alloca)
+}
+
+// generateGlobalVarStatement lowers a top-level variable to a global.
+// Constant values become the global initializer, any other value is
+// stored by the static initializer.
+func (gen *IRGenerator) generateGlobalVarStatement(stmt *parser.VarStatement) {
+	globalType := gen.context.Int8Type()
+	if stmt.Type != parser.Void {
+		globalType = gen.memoryType(stmt.Type)
+	}
+
+	global := llvm.AddGlobal(gen.Module, globalType, stmt.Name)
+	global.SetInitializer(llvm.ConstNull(globalType))
+
+	if stmt.Value != nil {
+		gen.staticInit(func() {
+			value := gen.generateExpression(stmt.Value, staticInitName)
+			if !value.IsConstant() {
+				gen.store(stmt.Type, value, global)
+				return
+			}
+
+			if stmt.Type == parser.Bool {
+				// folded by the builder, no instruction is emitted
+				value = gen.builder.CreateZExt(value, gen.context.Int8Type(), "")
+			}
+			global.SetInitializer(value)
+		})
+	}
+
+	gen.declareVar(stmt.Name, global)
+}
+
+// staticInit calls generate with the builder at the end of the
+// static initializer, the initializer is created on first use.
+func (gen *IRGenerator) staticInit(generate func()) {
+	if gen.initBlock.IsNil() {
+		fnType := llvm.FunctionType(gen.context.VoidType(), nil, false)
+		fn := llvm.AddFunction(gen.Module, staticInitName, fnType)
+		fn.SetLinkage(llvm.InternalLinkage)
+		gen.initBlock = llvm.AddBasicBlock(fn, "entry")
+	}
+
+	gen.builder.SetInsertPointAtEnd(gen.initBlock)
+	generate()
+	// the value may have created blocks on its own
+	gen.initBlock = gen.builder.GetInsertBlock()
+}
+
+// finishStaticInit terminates the static initializer and registers it
+// in llvm.global_ctors, it is dropped if every global was constant.
+func (gen *IRGenerator) finishStaticInit() {
+	if gen.initBlock.IsNil() {
+		return
+	}
+
+	fn := gen.initBlock.Parent()
+	if fn.FirstBasicBlock() == gen.initBlock && gen.initBlock.FirstInstruction().IsNil() {
+		fn.EraseFromParentAsFunction()
+		return
+	}
+
+	gen.builder.SetInsertPointAtEnd(gen.initBlock)
+	gen.builder.CreateRetVoid()
+
+	bytePtr := llvm.PointerType(gen.context.Int8Type(), 0)
+	ctorType := gen.context.StructType([]llvm.Type{gen.context.Int32Type(), fn.Type(), bytePtr}, false)
+	ctor := gen.context.ConstStruct([]llvm.Value{
+		llvm.ConstInt(gen.context.Int32Type(), 65535, false),
+		fn,
+		llvm.ConstNull(bytePtr),
+	}, false)
+
+	ctors := llvm.AddGlobal(gen.Module, llvm.ArrayType(ctorType, 1), "llvm.global_ctors")
+	ctors.SetLinkage(llvm.AppendingLinkage)
+	ctors.SetInitializer(llvm.ConstArray(ctorType, []llvm.Value{ctor}))
+}
+
+// generateBlock generates the statements of a block in a new scope.
+func (gen *IRGenerator) generateBlock(stmts []parser.Node, fnName string) {
+	gen.scopes = append(gen.scopes, map[string]llvm.Value{})
+	gen.generate(stmts, fnName)
+	gen.scopes = gen.scopes[:len(gen.scopes)-1]
+}
+
+// declareVar binds a variable storage in the innermost scope,
+// variables declared outside functions are globals.
+func (gen *IRGenerator) declareVar(name string, ptr llvm.Value) {
+	if len(gen.scopes) == 0 {
+		gen.globals[name] = ptr
+		return
+	}
+	gen.scopes[len(gen.scopes)-1][name] = ptr
+}
+
+// lookupVar returns the storage of the innermost variable with the
+// given name, looking at the globals last.
+func (gen *IRGenerator) lookupVar(name string) (llvm.Value, bool) {
+	for i := len(gen.scopes) - 1; i >= 0; i-- {
+		if ptr, ok := gen.scopes[i][name]; ok {
+			return ptr, true
+		}
 	}
 
-	if fnName != "" {
-		gen.locals[fnName][stmt.Name] = alloca
+	ptr, ok := gen.globals[name]
+	return ptr, ok
+}
+
+// createEntryAlloca allocates a local variable in the entry block of the
+// current function, so variables declared inside loops do not grow
+// the stack on every iteration.
+func (gen *IRGenerator) createEntryAlloca(typ llvm.Type, name string) llvm.Value {
+	builder := gen.context.NewBuilder()
+	defer builder.Dispose()
+
+	entry := gen.builder.GetInsertBlock().Parent().EntryBasicBlock()
+	if first := entry.FirstInstruction(); !first.IsNil() {
+		builder.SetInsertPointBefore(first)
 	} else {
-		gen.globals[stmt.Name] = alloca
+		builder.SetInsertPointAtEnd(entry)
+	}
+
+	return builder.CreateAlloca(typ, name)
+}
+
+// generateReassignStatement stores a new value in the variable
+// storage, so every branch observes the assignment. Top-level
+// assignments run in the static initializer.
+func (gen *IRGenerator) generateReassignStatement(stmt *parser.ReassignVarStatement, fnName string) {
+	alloca, ok := gen.lookupVar(stmt.VarName)
+	if !ok {
+		gen.generateVarStatement(stmt.ToVarAssignment(), fnName)
+		return
+	}
+
+	if fnName == "" {
+		gen.staticInit(func() {
+			gen.store(stmt.Type, gen.generateExpression(stmt.Value, staticInitName), alloca)
+		})
+		return
 	}
+
+	gen.store(stmt.Type, gen.generateExpression(stmt.Value, fnName), alloca)
 }
 
 func (gen *IRGenerator) fromRawTypeToLLVMType(rawType parser.Type) llvm.Type {
 	switch rawType {
-	case parser.Int32:
+	case parser.Int8, parser.Uint8:
+		return gen.context.Int8Type()
+	case parser.Int16, parser.Uint16:
+		return gen.context.Int16Type()
+	case parser.Int32, parser.Uint32:
 		return gen.context.Int32Type()
+	case parser.Int64, parser.Uint64:
+		return gen.context.Int64Type()
+	case parser.Isize, parser.Usize:
+		return gen.context.IntType(gen.targetData.PointerSize() * 8)
 	case parser.String:
 		return stringType
 	case parser.Void:
 		return gen.context.VoidType()
 	case parser.Float32:
 		return gen.context.FloatType()
+	case parser.Float64:
+		return gen.context.DoubleType()
+	case parser.Bool:
+		return gen.context.Int1Type()
+	case parser.Char:
+		return gen.context.Int32Type()
 	default:
-		panic(fmt.Sprintf("type %v not supported", rawType))
+		if gen.types.Array(rawType) != nil {
+			return gen.arrayType(rawType)
+		}
+		return gen.structType(rawType)
+	}
+}
+
+// memoryType returns the type used to store values of rawType in memory,
+// bools are i1 values but take a whole byte in memory like in C.
+func (gen *IRGenerator) memoryType(rawType parser.Type) llvm.Type {
+	if rawType == parser.Bool {
+		return gen.context.Int8Type()
 	}
+	return gen.fromRawTypeToLLVMType(rawType)
+}
+
+// store writes value to ptr using the memory representation of rawType.
+func (gen *IRGenerator) store(rawType parser.Type, value, ptr llvm.Value) {
+	gen.builder.CreateStore(gen.toMemory(rawType, value), ptr)
+}
+
+// load reads a value of rawType from ptr, converting it from its
+// memory representation.
+func (gen *IRGenerator) load(rawType parser.Type, ptr llvm.Value, name string) llvm.Value {
+	return gen.fromMemory(rawType, gen.builder.CreateLoad(gen.memoryType(rawType), ptr, name))
+}
+
+// toMemory converts a value of rawType to its memory representation.
+func (gen *IRGenerator) toMemory(rawType parser.Type, value llvm.Value) llvm.Value {
+	if rawType == parser.Bool {
+		return gen.builder.CreateZExt(value, gen.context.Int8Type(), "frombool")
+	}
+	return value
+}
+
+// fromMemory converts a value of rawType from its memory representation.
+func (gen *IRGenerator) fromMemory(rawType parser.Type, value llvm.Value) llvm.Value {
+	if rawType == parser.Bool {
+		return gen.builder.CreateTrunc(value, gen.context.Int1Type(), "tobool")
+	}
+	return value
 }
 
 func (gen *IRGenerator) getFnSignatureType(stmt *parser.FnStatement) llvm.Type {
@@ -122,15 +414,26 @@ func (gen *IRGenerator) generateFnStatement(stmt *parser.FnStatement) {
 	entry := llvm.AddBasicBlock(fn, "entry")
 	gen.builder.SetInsertPointAtEnd(entry)
 
-	gen.locals = make(map[string]map[string]llvm.Value)
-	gen.locals[stmt.Name] = make(map[string]llvm.Value)
+	// parameters are copied to the stack, so they
+	// can be reassigned like any other variable
+	params := map[string]llvm.Value{}
+	for idx, arg := range stmt.Args {
+		param := fn.Param(idx)
+		param.SetName(arg.Name)
+
+		alloca := gen.createEntryAlloca(gen.memoryType(arg.Type), arg.Name)
+		gen.store(arg.Type, param, alloca)
+		params[arg.Name] = alloca
+	}
+	gen.scopes = []map[string]llvm.Value{params}
+	defer func() { gen.scopes = nil }()
 
 	if len(stmt.Body) == 0 {
 		gen.builder.CreateRetVoid()
 		return
 	}
 
-	gen.generate(stmt.Body, stmt.Name)
+	gen.generateBlock(stmt.Body, stmt.Name)
 }
 
 // generateReturnStatement generates LLVM IR for a return statement.
@@ -144,32 +447,296 @@ func (gen *IRGenerator) generateReturnStatement(stmt *parser.ReturnStatement, fn
 	}
 }
 
+// generateIfStatement lowers an if statement to a conditional branch
+// into the then and else blocks, both falling through to a merge block.
+// The merge block is dropped when every branch returns.
+func (gen *IRGenerator) generateIfStatement(stmt *parser.IfStatement, fnName string) {
+	condition := gen.generateExpression(stmt.Condition, fnName)
+
+	fn := gen.builder.GetInsertBlock().Parent()
+	thenBlock := llvm.AddBasicBlock(fn, "if.then")
+	mergeBlock := llvm.AddBasicBlock(fn, "if.end")
+
+	elseBlock := mergeBlock
+	if stmt.Alternative != nil {
+		elseBlock = llvm.InsertBasicBlock(mergeBlock, "if.else")
+	}
+
+	gen.builder.CreateCondBr(condition, thenBlock, elseBlock)
+
+	gen.builder.SetInsertPointAtEnd(thenBlock)
+	gen.generateBlock(stmt.Consequence, fnName)
+	reachable := gen.branchTo(mergeBlock)
+
+	if stmt.Alternative != nil {
+		// keep the blocks in source order, after the
+		// ones created by the then branch
+		elseBlock.MoveAfter(gen.builder.GetInsertBlock())
+		gen.builder.SetInsertPointAtEnd(elseBlock)
+		gen.generateBlock(stmt.Alternative, fnName)
+		reachable = gen.branchTo(mergeBlock) || reachable
+	} else {
+		reachable = true
+	}
+
+	if !reachable {
+		mergeBlock.EraseFromParent()
+		return
+	}
+
+	mergeBlock.MoveAfter(gen.builder.GetInsertBlock())
+	gen.builder.SetInsertPointAtEnd(mergeBlock)
+}
+
+// generateWhileStatement lowers a loop to a header block checking the
+// condition, a body block jumping back to the header and an exit block.
+// Infinite loops have no header, continue jumps to the body instead.
+func (gen *IRGenerator) generateWhileStatement(stmt *parser.WhileStatement, fnName string) {
+	fn := gen.builder.GetInsertBlock().Parent()
+
+	var headerBlock llvm.BasicBlock
+	if stmt.Condition != nil {
+		headerBlock = llvm.AddBasicBlock(fn, "while.cond")
+	}
+	bodyBlock := llvm.AddBasicBlock(fn, "while.body")
+	exitBlock := llvm.AddBasicBlock(fn, "while.end")
+
+	if stmt.Condition != nil {
+		gen.builder.CreateBr(headerBlock)
+		gen.builder.SetInsertPointAtEnd(headerBlock)
+		condition := gen.generateExpression(stmt.Condition, fnName)
+		gen.builder.CreateCondBr(condition, bodyBlock, exitBlock)
+	} else {
+		headerBlock = bodyBlock
+		gen.builder.CreateBr(bodyBlock)
+	}
+
+	gen.loops = append(gen.loops, loop{
+		label:         stmt.Label,
+		continueBlock: headerBlock,
+		breakBlock:    exitBlock,
+	})
+
+	gen.builder.SetInsertPointAtEnd(bodyBlock)
+	gen.generateBlock(stmt.Body, fnName)
+	gen.branchTo(headerBlock)
+
+	gen.loops = gen.loops[:len(gen.loops)-1]
+
+	// an infinite loop without break never exits
+	if exitBlock.AsValue().FirstUse().IsNil() {
+		exitBlock.EraseFromParent()
+		return
+	}
+
+	exitBlock.MoveAfter(gen.builder.GetInsertBlock())
+	gen.builder.SetInsertPointAtEnd(exitBlock)
+}
+
+// generateForStatement lowers a range loop to the rotated form LLVM
+// loop passes expect: a guard skipping empty ranges, a preheader, a body
+// whose induction variable is a phi and a single latch. The preheader
+// computes the last value taken by the variable, so the latch compares
+// against it and the increment never overflows.
+func (gen *IRGenerator) generateForStatement(stmt *parser.ForStatement, fnName string) {
+	varType := gen.fromRawTypeToLLVMType(stmt.Variable.Type)
+	start := gen.generateExpression(stmt.Start, fnName)
+	end := gen.generateExpression(stmt.End, fnName)
+	step := llvm.ConstInt(varType, uint64(stmt.Step), false)
+
+	// the bounds are compared by the signedness of the variable
+	lessThan, lessOrEqual := llvm.IntSLT, llvm.IntSLE
+	if !stmt.Variable.Type.IsSigned() {
+		lessThan, lessOrEqual = llvm.IntULT, llvm.IntULE
+	}
+
+	fn := gen.builder.GetInsertBlock().Parent()
+	preheaderBlock := llvm.AddBasicBlock(fn, "for.ph")
+	bodyBlock := llvm.AddBasicBlock(fn, "for.body")
+	latchBlock := llvm.AddBasicBlock(fn, "for.inc")
+	exitBlock := llvm.AddBasicBlock(fn, "for.end")
+
+	var guard llvm.Value
+	if stmt.Inclusive {
+		guard = gen.builder.CreateICmp(lessOrEqual, start, end, "for.guard")
+	} else {
+		guard = gen.builder.CreateICmp(lessThan, start, end, "for.guard")
+	}
+	gen.builder.CreateCondBr(guard, preheaderBlock, exitBlock)
+
+	// the range is not empty here, so the last value is in [start, end]
+	gen.builder.SetInsertPointAtEnd(preheaderBlock)
+	last := end
+	if !stmt.Inclusive {
+		last = gen.builder.CreateSub(end, llvm.ConstInt(varType, 1, false), "for.last")
+	}
+	if stmt.Step != 1 {
+		distance := gen.builder.CreateSub(last, start, "for.distance")
+		steps := gen.builder.CreateUDiv(distance, step, "for.steps")
+		last = gen.builder.CreateAdd(start, gen.builder.CreateMul(steps, step, "for.span"), "for.last")
+	}
+	gen.builder.CreateBr(bodyBlock)
+
+	gen.builder.SetInsertPointAtEnd(bodyBlock)
+	induction := gen.builder.CreatePHI(varType, stmt.Variable.Name)
+
+	// the body reads the variable from memory like any other
+	// local, a fresh copy of the induction value per iteration
+	alloca := gen.createEntryAlloca(varType, stmt.Variable.Name)
+	gen.builder.CreateStore(induction, alloca)
+
+	gen.scopes = append(gen.scopes, map[string]llvm.Value{stmt.Variable.Name: alloca})
+	gen.loops = append(gen.loops, loop{
+		label:         stmt.Label,
+		continueBlock: latchBlock,
+		breakBlock:    exitBlock,
+	})
+
+	gen.generateBlock(stmt.Body, fnName)
+	gen.branchTo(latchBlock)
+
+	gen.loops = gen.loops[:len(gen.loops)-1]
+	gen.scopes = gen.scopes[:len(gen.scopes)-1]
+
+	latchBlock.MoveAfter(gen.builder.GetInsertBlock())
+	gen.builder.SetInsertPointAtEnd(latchBlock)
+	// the induction value never goes past last, so it never wraps
+	var next llvm.Value
+	if stmt.Variable.Type.IsSigned() {
+		next = gen.builder.CreateNSWAdd(induction, step, "for.next")
+	} else {
+		next = gen.builder.CreateNUWAdd(induction, step, "for.next")
+	}
+	more := gen.builder.CreateICmp(lessThan, induction, last, "for.more")
+	gen.builder.CreateCondBr(more, bodyBlock, exitBlock)
+
+	induction.AddIncoming(
+		[]llvm.Value{start, next},
+		[]llvm.BasicBlock{preheaderBlock, latchBlock},
+	)
+
+	exitBlock.MoveAfter(latchBlock)
+	gen.builder.SetInsertPointAtEnd(exitBlock)
+}
+
+// findLoop returns the loop with the given label,
+// or the innermost loop when the label is empty.
+func (gen *IRGenerator) findLoop(label string) loop {
+	for i := len(gen.loops) - 1; i >= 0; i-- {
+		if label == "" || gen.loops[i].label == label {
+			return gen.loops[i]
+		}
+	}
+
+	panic(fmt.Sprintf("loop not found: %q", label))
+}
+
+// branchTo closes the current block with a branch to target, unless
+// it already ends with a terminator. It reports if the branch was added.
+func (gen *IRGenerator) branchTo(target llvm.BasicBlock) bool {
+	if gen.blockTerminated() {
+		return false
+	}
+
+	gen.builder.CreateBr(target)
+	return true
+}
+
+// blockTerminated reports whether the current block already
+// ends with a terminator instruction, like a return or a branch.
+func (gen *IRGenerator) blockTerminated() bool {
+	last := gen.builder.GetInsertBlock().LastInstruction()
+	if last.IsNil() {
+		return false
+	}
+
+	switch last.InstructionOpcode() {
+	case llvm.Ret, llvm.Br, llvm.Switch, llvm.IndirectBr, llvm.Unreachable:
+		return true
+	default:
+		return false
+	}
+}
+
 // generateExpression generates LLVM IR for an expression.
 func (gen *IRGenerator) generateExpression(expr parser.Expression, fnName string) llvm.Value {
 	switch expr := expr.(type) {
 	case *parser.IntegerLiteral:
-		return llvm.ConstInt(gen.context.Int32Type(), uint64(expr.Value), false)
+		// literals without a type default to int32
+		literalType := expr.Type
+		if literalType == parser.Void {
+			literalType = parser.Int32
+		}
+		return llvm.ConstInt(gen.fromRawTypeToLLVMType(literalType), uint64(expr.Value), false)
 	case *parser.StringLiteral:
 		return llvm.ConstString(expr.Value, true)
 	case *parser.FloatLiteral:
-		return llvm.ConstFloat(gen.context.FloatType(), expr.Value)
+		// literals without a type default to float32
+		literalType := expr.Type
+		if literalType == parser.Void {
+			literalType = parser.Float32
+		}
+		return llvm.ConstFloat(gen.fromRawTypeToLLVMType(literalType), expr.Value)
+	case *parser.CharLiteral:
+		return llvm.ConstInt(gen.context.Int32Type(), uint64(expr.Value), false)
+	case *parser.BooleanLiteral:
+		value := uint64(0)
+		if expr.Value {
+			value = 1
+		}
+		return llvm.ConstInt(gen.context.Int1Type(), value, false)
+	case *parser.CastExpression:
+		return gen.generateCast(expr, fnName)
+	case *parser.StructLiteral:
+		return gen.generateStructLiteral(expr, fnName)
+	case *parser.FieldAccess:
+		return gen.generateFieldAccess(expr, fnName)
+	case *parser.ArrayLiteral:
+		return gen.generateArrayLiteral(expr, fnName)
+	case *parser.IndexExpression:
+		return gen.generateIndexExpression(expr, fnName)
+	case *parser.SliceExpression:
+		return gen.generateSliceExpression(expr, fnName)
+	case *parser.LenExpression:
+		return gen.generateLenExpression(expr, fnName)
 	case *parser.Identifier:
-		return gen.builder.CreateLoad(gen.context.Int32Type(), gen.locals[fnName][expr.Value], expr.Value)
+		ptr, ok := gen.lookupVar(expr.Value)
+		if !ok {
+			panic(fmt.Sprintf("variable not found: %s", expr.Value))
+		}
+		return gen.load(expr.Type, ptr, expr.Value)
 	case *parser.InfixExpression:
+		if expr.Operator == "&&" || expr.Operator == "||" {
+			return gen.generateLogicalExpression(expr, fnName)
+		}
+
 		left := gen.generateExpression(expr.Left, fnName)
 		right := gen.generateExpression(expr.Right, fnName)
 
+		switch left.Type().TypeKind() {
+		case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+			return gen.generateFloatInfix(expr.Operator, left, right)
+		default:
+			// operands share their type, untyped literals are int32
+			operandType := parser.TypeOf(expr.Left)
+			signed := operandType == parser.Void || operandType.IsSigned()
+			return gen.generateIntInfix(expr.Operator, left, right, signed)
+		}
+	case *parser.PrefixExpression:
+		right := gen.generateExpression(expr.Right, fnName)
+
 		switch expr.Operator {
-		case "+":
-			return gen.builder.CreateAdd(left, right, "addtmp")
 		case "-":
-			return gen.builder.CreateSub(left, right, "subtmp")
-		case "*":
-			return gen.builder.CreateMul(left, right, "multmp")
-		case "/":
-			return gen.builder.CreateSDiv(left, right, "divtmp")
+			switch right.Type().TypeKind() {
+			case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+				return gen.builder.CreateFNeg(right, "negtmp")
+			default:
+				return gen.builder.CreateNeg(right, "negtmp")
+			}
+		case "!", "~":
+			return gen.builder.CreateNot(right, "nottmp")
 		default:
-			panic(fmt.Sprintf("unknown operator: %s", expr.Operator))
+			panic(fmt.Sprintf("unknown prefix operator: %s", expr.Operator))
 		}
 	case *parser.FnCall:
 		fn, ok := gen.getFn(expr.FnName)
@@ -182,17 +749,319 @@ func (gen *IRGenerator) generateExpression(expr parser.Expression, fnName string
 			args = append(args, gen.generateExpression(arg, fnName))
 		}
 
-		return gen.builder.CreateCall(
-			fn.Type,
-			fn.Value,
-			args,
-			fmt.Sprintf("call%s", expr.FnName),
-		)
+		// void results cannot be named
+		name := fmt.Sprintf("call%s", expr.FnName)
+		if fn.Type.ReturnType().TypeKind() == llvm.VoidTypeKind {
+			name = ""
+		}
+
+		return gen.builder.CreateCall(fn.Type, fn.Value, args, name)
 	default:
 		panic(fmt.Sprintf("unknown expression type: %T", expr))
 	}
 }
 
+// generateIntInfix lowers an operation over integers, signed picks
+// the division, remainder, right shift and ordering instructions.
+func (gen *IRGenerator) generateIntInfix(operator string, left, right llvm.Value, signed bool) llvm.Value {
+	if !signed {
+		switch operator {
+		case "/":
+			return gen.builder.CreateUDiv(left, right, "divtmp")
+		case "%":
+			return gen.builder.CreateURem(left, right, "remtmp")
+		case ">>":
+			return gen.builder.CreateLShr(left, right, "shrtmp")
+		case "<":
+			return gen.builder.CreateICmp(llvm.IntULT, left, right, "lttmp")
+		case "<=":
+			return gen.builder.CreateICmp(llvm.IntULE, left, right, "letmp")
+		case ">":
+			return gen.builder.CreateICmp(llvm.IntUGT, left, right, "gttmp")
+		case ">=":
+			return gen.builder.CreateICmp(llvm.IntUGE, left, right, "getmp")
+		}
+	}
+
+	switch operator {
+	case "+":
+		return gen.builder.CreateAdd(left, right, "addtmp")
+	case "-":
+		return gen.builder.CreateSub(left, right, "subtmp")
+	case "*":
+		return gen.builder.CreateMul(left, right, "multmp")
+	case "/":
+		return gen.builder.CreateSDiv(left, right, "divtmp")
+	case "%":
+		return gen.builder.CreateSRem(left, right, "remtmp")
+	case "&":
+		return gen.builder.CreateAnd(left, right, "andtmp")
+	case "|":
+		return gen.builder.CreateOr(left, right, "ortmp")
+	case "^":
+		return gen.builder.CreateXor(left, right, "xortmp")
+	case "<<":
+		return gen.builder.CreateShl(left, right, "shltmp")
+	case ">>":
+		return gen.builder.CreateAShr(left, right, "shrtmp")
+	case "==":
+		return gen.builder.CreateICmp(llvm.IntEQ, left, right, "eqtmp")
+	case "!=":
+		return gen.builder.CreateICmp(llvm.IntNE, left, right, "netmp")
+	case "<":
+		return gen.builder.CreateICmp(llvm.IntSLT, left, right, "lttmp")
+	case "<=":
+		return gen.builder.CreateICmp(llvm.IntSLE, left, right, "letmp")
+	case ">":
+		return gen.builder.CreateICmp(llvm.IntSGT, left, right, "gttmp")
+	case ">=":
+		return gen.builder.CreateICmp(llvm.IntSGE, left, right, "getmp")
+	default:
+		panic(fmt.Sprintf("unknown operator: %s", operator))
+	}
+}
+
+func (gen *IRGenerator) generateFloatInfix(operator string, left, right llvm.Value) llvm.Value {
+	switch operator {
+	case "+":
+		return gen.builder.CreateFAdd(left, right, "addtmp")
+	case "-":
+		return gen.builder.CreateFSub(left, right, "subtmp")
+	case "*":
+		return gen.builder.CreateFMul(left, right, "multmp")
+	case "/":
+		return gen.builder.CreateFDiv(left, right, "divtmp")
+	case "%":
+		return gen.builder.CreateFRem(left, right, "remtmp")
+	case "==":
+		return gen.builder.CreateFCmp(llvm.FloatOEQ, left, right, "eqtmp")
+	case "!=":
+		// unordered, so NaN != NaN holds
+		return gen.builder.CreateFCmp(llvm.FloatUNE, left, right, "netmp")
+	case "<":
+		return gen.builder.CreateFCmp(llvm.FloatOLT, left, right, "lttmp")
+	case "<=":
+		return gen.builder.CreateFCmp(llvm.FloatOLE, left, right, "letmp")
+	case ">":
+		return gen.builder.CreateFCmp(llvm.FloatOGT, left, right, "gttmp")
+	case ">=":
+		return gen.builder.CreateFCmp(llvm.FloatOGE, left, right, "getmp")
+	default:
+		panic(fmt.Sprintf("unknown float operator: %s", operator))
+	}
+}
+
+// generateLogicalExpression lowers && and || with short-circuit evaluation,
+// the right operand is only evaluated when the left one does not
+// already decide the result.
+func (gen *IRGenerator) generateLogicalExpression(expr *parser.InfixExpression, fnName string) llvm.Value {
+	left := gen.generateExpression(expr.Left, fnName)
+	leftBlock := gen.builder.GetInsertBlock()
+
+	fn := leftBlock.Parent()
+	rhsBlock := llvm.AddBasicBlock(fn, "logic.rhs")
+	mergeBlock := llvm.AddBasicBlock(fn, "logic.end")
+
+	// value of the expression when the right operand is skipped
+	var shortCircuit llvm.Value
+	if expr.Operator == "&&" {
+		gen.builder.CreateCondBr(left, rhsBlock, mergeBlock)
+		shortCircuit = llvm.ConstInt(gen.context.Int1Type(), 0, false)
+	} else {
+		gen.builder.CreateCondBr(left, mergeBlock, rhsBlock)
+		shortCircuit = llvm.ConstInt(gen.context.Int1Type(), 1, false)
+	}
+
+	gen.builder.SetInsertPointAtEnd(rhsBlock)
+	right := gen.generateExpression(expr.Right, fnName)
+	// the right operand may have created blocks on its own
+	rhsBlock = gen.builder.GetInsertBlock()
+	gen.builder.CreateBr(mergeBlock)
+
+	gen.builder.SetInsertPointAtEnd(mergeBlock)
+	phi := gen.builder.CreatePHI(gen.context.Int1Type(), "logictmp")
+	phi.AddIncoming([]llvm.Value{shortCircuit, right}, []llvm.BasicBlock{leftBlock, rhsBlock})
+	return phi
+}
+
+// generateCast generates LLVM IR converting a value between types.
+// Integers wrap or are extended by the source sign, floats converted to
+// integers saturate at the bounds of the integer and NaN becomes zero.
+// Checked casts trap when the converted value differs from the source.
+func (gen *IRGenerator) generateCast(expr *parser.CastExpression, fnName string) llvm.Value {
+	value := gen.generateExpression(expr.Value, fnName)
+	result := gen.convert(value, expr.From, expr.Type)
+
+	if expr.Checked {
+		gen.trapUnless(gen.exactConversion(value, result, expr.From, expr.Type), "cast")
+	}
+	return result
+}
+
+func (gen *IRGenerator) convert(value llvm.Value, fromType, toType parser.Type) llvm.Value {
+	// char and int32 share the same representation
+	from, to := gen.fromRawTypeToLLVMType(fromType), gen.fromRawTypeToLLVMType(toType)
+	if from == to {
+		return value
+	}
+
+	switch {
+	case fromType.IsFloat() && toType.IsFloat():
+		if fromType == parser.Float64 {
+			return gen.builder.CreateFPTrunc(value, to, "fptrunctmp")
+		}
+		return gen.builder.CreateFPExt(value, to, "fpexttmp")
+	case toType.IsFloat():
+		if fromType.IsSigned() {
+			return gen.builder.CreateSIToFP(value, to, "sitofptmp")
+		}
+		return gen.builder.CreateUIToFP(value, to, "uitofptmp")
+	case fromType.IsFloat():
+		return gen.saturatingFloatToInt(value, to, toType.IsSigned())
+	}
+
+	if from.TypeKind() != llvm.IntegerTypeKind || to.TypeKind() != llvm.IntegerTypeKind {
+		panic(fmt.Sprintf("cannot cast %s to %s", fromType, toType))
+	}
+
+	// narrower integers wrap, wider ones are extended by the source sign
+	switch {
+	case from.IntTypeWidth() > to.IntTypeWidth():
+		return gen.builder.CreateTrunc(value, to, "trunctmp")
+	case fromType.IsSigned():
+		return gen.builder.CreateSExt(value, to, "sexttmp")
+	default:
+		return gen.builder.CreateZExt(value, to, "zexttmp")
+	}
+}
+
+// saturatingFloatToInt truncates a float toward zero with the
+// llvm.fpto[su]i.sat intrinsics, which are defined for every input.
+func (gen *IRGenerator) saturatingFloatToInt(value llvm.Value, to llvm.Type, signed bool) llvm.Value {
+	name := "llvm.fptoui.sat"
+	if signed {
+		name = "llvm.fptosi.sat"
+	}
+
+	floatName := "f32"
+	if value.Type().TypeKind() == llvm.DoubleTypeKind {
+		floatName = "f64"
+	}
+
+	fnType := llvm.FunctionType(to, []llvm.Type{value.Type()}, false)
+	fn := gen.intrinsic(fmt.Sprintf("%s.i%d.%s", name, to.IntTypeWidth(), floatName), fnType)
+	return gen.builder.CreateCall(fnType, fn, []llvm.Value{value}, "sattmp")
+}
+
+// exactConversion returns an i1 telling whether result, the conversion
+// of value from fromType to toType, holds the same number as value.
+func (gen *IRGenerator) exactConversion(value, result llvm.Value, fromType, toType parser.Type) llvm.Value {
+	switch {
+	case fromType.IsFloat() && toType.IsFloat():
+		if fromType == toType || fromType == parser.Float32 {
+			return llvm.ConstInt(gen.context.Int1Type(), 1, false)
+		}
+
+		// the value is exact if it is the same once widened back, NaN
+		// converts to NaN so unordered values are exact too
+		back := gen.builder.CreateFPExt(result, value.Type(), "")
+		return gen.builder.CreateFCmp(llvm.FloatUEQ, back, value, "exacttmp")
+	case toType.IsFloat():
+		// a value rounded up to the first power of two out of the range
+		// of the integer cannot be converted back
+		limit := gen.integerLimit(result.Type(), value.Type().IntTypeWidth(), fromType.IsSigned())
+		inRange := gen.builder.CreateFCmp(llvm.FloatOLT, result, limit, "")
+
+		back := gen.saturatingFloatToInt(result, value.Type(), fromType.IsSigned())
+		same := gen.builder.CreateICmp(llvm.IntEQ, back, value, "")
+		return gen.builder.CreateAnd(inRange, same, "exacttmp")
+	case fromType.IsFloat():
+		// ordered comparisons are false for NaN
+		bits := result.Type().IntTypeWidth()
+		low := llvm.ConstFloat(value.Type(), 0)
+		if toType.IsSigned() {
+			low = llvm.ConstFloat(value.Type(), -math.Ldexp(1, bits-1))
+		}
+		limit := gen.integerLimit(value.Type(), bits, toType.IsSigned())
+
+		inRange := gen.builder.CreateAnd(
+			gen.builder.CreateFCmp(llvm.FloatOGE, value, low, ""),
+			gen.builder.CreateFCmp(llvm.FloatOLT, value, limit, ""),
+			"",
+		)
+
+		back := gen.convert(result, toType, fromType)
+		same := gen.builder.CreateFCmp(llvm.FloatOEQ, back, value, "")
+		return gen.builder.CreateAnd(inRange, same, "exacttmp")
+	}
+
+	exact := llvm.ConstInt(gen.context.Int1Type(), 1, false)
+
+	// narrowed values must extend back to the source value
+	if value.Type().IntTypeWidth() > result.Type().IntTypeWidth() {
+		back := gen.convert(result, toType, fromType)
+		exact = gen.builder.CreateICmp(llvm.IntEQ, back, value, "")
+	}
+
+	// reinterpreting the sign is only exact for non negative values
+	switch {
+	case fromType.IsSigned() && !toType.IsSigned():
+		nonNegative := gen.builder.CreateICmp(llvm.IntSGE, value, llvm.ConstInt(value.Type(), 0, false), "")
+		exact = gen.builder.CreateAnd(exact, nonNegative, "")
+	case !fromType.IsSigned() && toType.IsSigned():
+		nonNegative := gen.builder.CreateICmp(llvm.IntSGE, result, llvm.ConstInt(result.Type(), 0, false), "")
+		exact = gen.builder.CreateAnd(exact, nonNegative, "")
+	}
+
+	return exact
+}
+
+// integerLimit returns, as a float of type floatType, the first power
+// of two above the range of an integer with the given size in bits.
+func (gen *IRGenerator) integerLimit(floatType llvm.Type, bits int, signed bool) llvm.Value {
+	if signed {
+		bits--
+	}
+	return llvm.ConstFloat(floatType, math.Ldexp(1, bits))
+}
+
+// trapUnless continues code generation in a new block reached when
+// cond holds, the program traps otherwise. When given, message is the
+// format and the arguments written to the standard error before
+// trapping.
+func (gen *IRGenerator) trapUnless(cond llvm.Value, name string, message ...llvm.Value) {
+	fn := gen.builder.GetInsertBlock().Parent()
+	trapBlock := llvm.AddBasicBlock(fn, name+".trap")
+	okBlock := llvm.AddBasicBlock(fn, name+".ok")
+	gen.builder.CreateCondBr(cond, okBlock, trapBlock)
+
+	gen.builder.SetInsertPointAtEnd(trapBlock)
+	if len(message) > 0 {
+		i32 := gen.context.Int32Type()
+		dprintfType := llvm.FunctionType(i32, []llvm.Type{i32, llvm.PointerType(gen.context.Int8Type(), 0)}, true)
+
+		// 2 is the file descriptor of the standard error
+		args := append([]llvm.Value{llvm.ConstInt(i32, 2, false)}, message...)
+		gen.builder.CreateCall(dprintfType, gen.intrinsic("dprintf", dprintfType), args, "")
+	}
+
+	trapType := llvm.FunctionType(gen.context.VoidType(), nil, false)
+	gen.builder.CreateCall(trapType, gen.intrinsic("llvm.trap", trapType), nil, "")
+	gen.builder.CreateUnreachable()
+
+	gen.builder.SetInsertPointAtEnd(okBlock)
+}
+
+// intrinsic returns the declaration of an LLVM intrinsic or of a C
+// library function, the declaration is added to the module on first use.
+func (gen *IRGenerator) intrinsic(name string, fnType llvm.Type) llvm.Value {
+	fn := gen.Module.NamedFunction(name)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(gen.Module, name, fnType)
+	}
+	return fn
+}
+
 func (gen *IRGenerator) getFn(fnName string) (*Fn, bool) {
 	if fn, ok := gen.fns[fnName]; ok {
 		return fn, true
@@ -13,6 +13,66 @@ import (
 	gollvm "tinygo.org/x/go-llvm"
 )
 
+// compile parses src and generates its module, which must be valid.
+// Options configure the generator before the IR is generated.
+func compile(t *testing.T, src string, options ...func(*llvm.IRGenerator)) *llvm.IRGenerator {
+	t.Helper()
+
+	l := lexer.NewLexer(strings.NewReader(src))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	irGen := llvm.NewIRGenerator()
+	for _, option := range options {
+		option(irGen)
+	}
+	irGen.GenerateIR(program)
+
+	err = gollvm.VerifyModule(irGen.Module, gollvm.ReturnStatusAction)
+	require.NoError(t, err)
+	return irGen
+}
+
+// run executes the main function of the module with MCJIT, after
+// the static constructors initialized the globals.
+func run(t *testing.T, irGen *llvm.IRGenerator) gollvm.GenericValue {
+	t.Helper()
+
+	gollvm.LinkInMCJIT()
+	gollvm.InitializeNativeTarget()
+	gollvm.InitializeNativeAsmPrinter()
+
+	engine, err := gollvm.NewMCJITCompiler(irGen.Module, gollvm.NewMCJITCompilerOptions())
+	require.NoError(t, err)
+	t.Cleanup(engine.Dispose)
+
+	engine.RunStaticConstructors()
+	return engine.RunFunction(irGen.Module.NamedFunction("main"), nil)
+}
+
+// nativeMachine returns a target machine for the host.
+func nativeMachine(t *testing.T) gollvm.TargetMachine {
+	t.Helper()
+
+	gollvm.InitializeNativeTarget()
+	triple := gollvm.DefaultTargetTriple()
+	target, err := gollvm.GetTargetFromTriple(triple)
+	require.NoError(t, err)
+
+	machine := target.CreateTargetMachine(triple, "", "", gollvm.CodeGenLevelDefault, gollvm.RelocDefault, gollvm.CodeModelDefault)
+	t.Cleanup(machine.Dispose)
+	return machine
+}
+
+// withTarget generates the module for the machine target.
+func withTarget(machine gollvm.TargetMachine) func(*llvm.IRGenerator) {
+	return func(irGen *llvm.IRGenerator) {
+		irGen.SetTarget(machine)
+	}
+}
+
 func TestIRGenerator_GenerateIR(t *testing.T) {
 	input := `fn main() {
 	var x = 1;
@@ -58,3 +118,680 @@ func TestIRGenerator_GenerateIR(t *testing.T) {
 
 	//require.Equal(t, expectedIR, irGen.Module.String())
 }
+
+func TestIRGenerator_Operators(t *testing.T) {
+	input := `fn main(): int32 {
+	var a = 7;
+	var b = 3;
+	var c = a > b && a != 0 || !(b <= 1);
+	return a % b + (a & b) + (a | b) + (a ^ b) + (a << 1) + (a >> 1);
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "icmp sgt")
+	require.Contains(t, ir, "logic.rhs")
+	require.Contains(t, ir, "phi i1")
+
+	result := run(t, irGen)
+	require.Equal(t, uint64(32), result.Int(false))
+}
+
+func TestIRGenerator_Chars(t *testing.T) {
+	input := `fn main(): int32 {
+	var c = 'A';
+	var d = (c as int32 + 1) as char;
+	return c as int32 + d as int32 + '\u{1F600}' as int32;
+}`
+
+	irGen := compile(t, input)
+
+	result := run(t, irGen)
+	require.Equal(t, uint64('A'+'B'+0x1F600), result.Int(false))
+}
+
+func TestIRGenerator_IfStatements(t *testing.T) {
+	input := `fn sign(): int32 {
+	var x = 0 - 5
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return 0 - 1
+	} else {
+		return 0
+	}
+}
+
+fn main(): int32 {
+	var total = 0
+	var a = 3
+	if a == 3 {
+		total = total + 10
+	}
+
+	if a > 5 {
+		total = total + 100
+	} else {
+		total = total + 20
+		if a != 0 && a < 4 {
+			total = total + 1
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return total + sign()
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "if.then")
+	require.Contains(t, ir, "if.else")
+
+	result := run(t, irGen)
+	require.Equal(t, uint64(30), result.Int(false))
+}
+
+func TestIRGenerator_Loops(t *testing.T) {
+	input := `fn firstOver(limit: int32): int32 {
+	var n = 0
+	loop {
+		n = n + 7
+		if n > 20 {
+			return n
+		}
+	}
+}
+
+fn main(): int32 {
+	var total = 0
+	var i = 0
+	while i < 10 {
+		i = i + 1
+		if i % 2 == 0 {
+			continue
+		}
+		total = total + i
+	}
+
+	var pairs = 0
+	var a = 0
+	outer: loop {
+		a = a + 1
+		var b = 0
+		while b < 10 {
+			b = b + 1
+			if b > a {
+				continue outer
+			}
+			if a == 4 {
+				break outer
+			}
+			pairs = pairs + 1
+		}
+	}
+
+	return total * 100 + pairs + firstOver(0) * 1000
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "while.cond")
+	require.Contains(t, ir, "while.body")
+	require.Contains(t, ir, "while.end")
+
+	// odd numbers up to 10 sum 25, pairs (a, b) with b <= a < 4 are 6
+	result := run(t, irGen)
+	require.Equal(t, uint64(21*1000+25*100+6), result.Int(false))
+}
+
+func TestIRGenerator_ForLoops(t *testing.T) {
+	input := `fn main(): int32 {
+	var i = 100
+	var total = 0
+	for i in 0..10 {
+		total = total + i
+	}
+
+	for i in 1..=9 step 2 {
+		if i == 7 {
+			continue
+		}
+		total = total + i * 100
+	}
+
+	for i in 5..5 {
+		total = total + 1000
+	}
+
+	var edges = 0
+	for i in 2147483640..=2147483647 step 3 {
+		edges = edges + 1
+	}
+
+	outer: for a in 0..10 {
+		for b in 0..10 {
+			if a * b > 6 {
+				break outer
+			}
+			total = total + 10000
+		}
+	}
+
+	return total + i * 1000000 + edges * 10000000
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "for.body")
+	require.Contains(t, ir, "for.inc")
+
+	// 45 from the first loop, 1+3+5+9 from the second one, the nested
+	// loops run 10 times for a = 0 and 7 times for a = 1 before breaking
+	// and the last range takes 2147483640, 2147483643 and 2147483646
+	result := run(t, irGen)
+	require.Equal(t, uint64(45+1800+17*10000+100*1000000+3*10000000), result.Int(false))
+}
+
+func TestIRGenerator_ForLoopBoundTypes(t *testing.T) {
+	input := `fn main(): int64 {
+	var big: int64 = 5000000000
+	var total: int64 = 0
+	for i in big..big + 3 {
+		total = total + i - big
+	}
+
+	var low: uint32 = 2147483647
+	var high: uint32 = 4294967295
+	for j in low..=high step 1073741824 {
+		total = total + 100
+	}
+	return total
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "phi i64")
+	require.Contains(t, ir, "icmp ule i32")
+
+	// the int64 range takes 0, 1 and 2 past big, the uint32 range
+	// crosses 2147483647 and ends at the largest uint32
+	result := run(t, irGen)
+	require.Equal(t, uint64(3+3*100), result.Int(false))
+}
+
+func TestIRGenerator_ForLoopsAreCanonical(t *testing.T) {
+	input := `fn main(): int32 {
+	var total = 0
+	for i in 0..1000 {
+		total = total + i
+	}
+	return total
+}`
+
+	irGen := compile(t, input)
+	machine := nativeMachine(t)
+
+	// the loop passes compute the trip count and fold the whole loop
+	err := irGen.Module.RunPasses("default<O2>", machine, gollvm.NewPassBuilderOptions())
+	require.NoError(t, err)
+
+	ir := irGen.Module.String()
+	require.NotContains(t, ir, "for.body")
+	require.Contains(t, ir, "ret i32 499500")
+}
+
+func TestIRGenerator_Booleans(t *testing.T) {
+	input := `fn main(): int32 {
+	var ok: bool = true
+	var done = false
+	var count = 0
+	while !done {
+		count = count + 1
+		done = count == 3
+	}
+
+	var result = 0
+	if ok && done {
+		result = count
+	}
+	if ok == false {
+		result = 100
+	}
+	return result
+}`
+
+	irGen := compile(t, input)
+
+	// bools are stored as bytes and used as i1
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "%ok = alloca i8")
+	require.Contains(t, ir, "zext i1")
+	require.Contains(t, ir, "trunc i8")
+
+	result := run(t, irGen)
+	require.Equal(t, uint64(3), result.Int(false))
+}
+
+func TestIRGenerator_PrefixExpressions(t *testing.T) {
+	input := `fn main(): int32 {
+	var a = 5
+	var b = -a * 3
+	var c = ~b
+	var done = false
+	var result = 0
+	if !done && -a < 0 {
+		result = b + c
+	}
+	return -result
+}`
+
+	irGen := compile(t, input)
+
+	// b = -15, c = ~(-15) = 14, result = -1
+	result := run(t, irGen)
+	require.Equal(t, uint64(1), result.Int(false))
+}
+
+func TestIRGenerator_CompoundAssignments(t *testing.T) {
+	input := `fn bump(value: int32): int32 {
+	value += 2
+	return value
+}
+
+fn main(): int32 {
+	var total = 0
+	var i = 0
+	while i < 10 {
+		total += i * 2
+		i++
+	}
+	total -= 10
+	total *= 3
+	total /= 2
+	total %= 100
+	total--
+	return total + bump(1)
+}`
+
+	irGen := compile(t, input)
+
+	// value += 2 loads the variable once before storing the result
+	bump := irGen.Module.NamedFunction("bump").String()
+	require.Equal(t, 2, strings.Count(bump, "load i32"))
+	require.Equal(t, 2, strings.Count(bump, "store i32"))
+
+	// ((90 - 10) * 3 / 2) % 100 - 1 + 3
+	result := run(t, irGen)
+	require.Equal(t, uint64(22), result.Int(false))
+}
+
+func TestIRGenerator_Constants(t *testing.T) {
+	input := `const BASE: int32 = 40
+const OFFSET = BASE / 20
+
+fn main(): int32 {
+	const ENABLED = BASE > OFFSET
+	var result = 0
+	if ENABLED {
+		result = BASE + OFFSET
+	}
+	return result
+}`
+
+	irGen := compile(t, input)
+
+	// constants have no storage, their values are immediates
+	ir := irGen.Module.String()
+	require.NotContains(t, ir, "BASE")
+	require.NotContains(t, ir, "ENABLED")
+	require.Contains(t, ir, "br i1 true")
+
+	result := run(t, irGen)
+	require.Equal(t, uint64(42), result.Int(false))
+}
+
+func TestIRGenerator_Globals(t *testing.T) {
+	input := `var calls = 0
+var limit: int32 = 10 * 4
+var enabled = true
+var threshold = limit / 2 + calls
+calls += 1
+
+fn count(): int32 {
+	calls++
+	return calls
+}
+
+// top-level calls run in the static initializer too
+count()
+
+fn main(): int32 {
+	count()
+	count()
+	if enabled {
+		return calls * 100 + threshold + limit
+	}
+	return 0
+}`
+
+	irGen := compile(t, input)
+
+	// constant values are the initializers, others
+	// are computed by the static initializer
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "@limit = global i32 40")
+	require.Contains(t, ir, "@enabled = global i8 1")
+	require.Contains(t, ir, "@threshold = global i32 0")
+	require.Contains(t, ir, "@llvm.global_ctors")
+
+	// run calls the static constructors before main
+	result := run(t, irGen)
+	require.Equal(t, uint64(460), result.Int(false))
+}
+
+func TestIRGenerator_ConstantGlobalsHaveNoInitializer(t *testing.T) {
+	input := `var base = 40 + 2
+
+fn main(): int32 {
+	return base
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "@base = global i32 42")
+	require.NotContains(t, ir, "global_ctors")
+	require.True(t, irGen.Module.NamedFunction("lotus.init").IsNil())
+}
+
+func TestIRGenerator_IntegerTypes(t *testing.T) {
+	input := `fn main(): int64 {
+	var big: uint32 = 4000000000
+	var half = big / 2
+	var shifted = big >> 31
+	var small: int8 = -100
+	var widened: int64 = small
+	var bytes: uint8 = 200
+	var sum: int32 = bytes
+	var value = 300
+	var truncated = value as int8
+	var size: usize = 8
+	size += 1
+
+	if big > 1 {
+		return half + widened + sum + shifted + truncated as int64
+	}
+	return 0
+}`
+
+	machine := nativeMachine(t)
+	irGen := compile(t, input, withTarget(machine))
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "udiv i32")
+	require.Contains(t, ir, "lshr i32")
+	require.Contains(t, ir, "icmp ugt i32")
+	require.Contains(t, ir, "sext i8")
+	require.Contains(t, ir, "zext i8")
+	require.Contains(t, ir, "trunc i32")
+
+	// usize is as wide as a pointer of the target
+	pointerBits := machine.CreateTargetData().PointerSize() * 8
+	require.Contains(t, ir, fmt.Sprintf("%%size = alloca i%d", pointerBits))
+
+	// 2000000000 - 100 + 200 + 1 + 44
+	result := run(t, irGen)
+	require.Equal(t, int64(2000000145), int64(result.Int(true)))
+}
+
+func TestIRGenerator_FloatTypes(t *testing.T) {
+	input := `fn half(value: float64): float64 {
+	return value / 2.0
+}
+
+fn main(): float64 {
+	var ratio: float32 = 0.25
+	var total: float64 = ratio
+	var count: uint8 = 3
+	total = total + count as float64
+	total -= 0.5
+	var rounded = (half(total) * 10.0) as int32
+	var back = total as float32
+
+	if back > ratio && -total < 0.0 {
+		return total * rounded as float64
+	}
+	return 0.0
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "fdiv double")
+	require.Contains(t, ir, "fadd double")
+	require.Contains(t, ir, "fsub double")
+	require.Contains(t, ir, "fmul double")
+	require.Contains(t, ir, "fcmp ogt float")
+	require.Contains(t, ir, "fneg double")
+	require.Contains(t, ir, "fpext float")
+	require.Contains(t, ir, "fptrunc double")
+	require.Contains(t, ir, "uitofp i8")
+	require.Contains(t, ir, "@llvm.fptosi.sat.i32.f64(double")
+	require.Contains(t, ir, "sitofp i32")
+
+	// total is 0.25 + 3 - 0.5 = 2.75, rounded is 13.75 as int32 = 13
+	result := run(t, irGen)
+	require.Equal(t, 35.75, result.Float(gollvm.GlobalContext().DoubleType()))
+}
+
+func TestIRGenerator_CastExpressions(t *testing.T) {
+	input := `fn main(): int64 {
+	var big: int64 = 300
+	var wrapped = big as int8
+	var count: uint16 = 65535
+	var reinterpreted = count as int16
+	var ratio: float64 = -2.75
+	var truncated = ratio as int32
+	var huge: float64 = 1e20
+	var saturated = huge as int32
+	var exact = (big - 100) as! uint8
+	var back = exact as! float32 as! int64
+	return wrapped as int64 + reinterpreted as int64 + truncated as int64 + (saturated as int64 - 2147483647) + back
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "trunc i64")
+	require.Contains(t, ir, "@llvm.fptosi.sat.i32.f64(double")
+	require.Contains(t, ir, "uitofp i8")
+	require.Contains(t, ir, "cast.trap:")
+	require.Contains(t, ir, "call void @llvm.trap()")
+
+	// 44 - 1 - 2 + 0 + 200, none of the checked casts trap
+	result := run(t, irGen)
+	require.Equal(t, int64(241), int64(result.Int(true)))
+}
+
+func TestIRGenerator_Structs(t *testing.T) {
+	input := `struct Point {
+	x: int32
+	y: int32
+}
+
+struct Rect { origin: Point, size: Point, filled: bool }
+
+var unit = Point { x: 1, y: 1 }
+
+fn area(r: Rect): int32 {
+	return r.size.x * r.size.y
+}
+
+fn grow(r: Rect, by: int32): Rect {
+	r.size.x += by
+	r.size.y += by
+	return r
+}
+
+fn main(): int32 {
+	var r = Rect { origin: unit, size: Point { x: 2, y: 3 }, filled: false }
+	r.filled = r.origin.x == 1
+	var bigger = grow(r, unit.y)
+	if bigger.filled {
+		return area(bigger) * 10 + r.size.x
+	}
+	return 0
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "%struct.Point = type { i32, i32 }")
+	require.Contains(t, ir, "%struct.Rect = type { %struct.Point, %struct.Point, i8 }")
+	require.Contains(t, ir, "@unit = global %struct.Point { i32 1, i32 1 }")
+	require.Contains(t, ir, "define %struct.Rect @grow(%struct.Rect %r, i32 %by)")
+
+	// grow works on a copy, so r keeps its size
+	result := run(t, irGen)
+	require.Equal(t, uint64(122), result.Int(false))
+}
+
+func TestIRGenerator_StructLayout(t *testing.T) {
+	input := `struct Header {
+	tag: uint8
+	length: int64
+	flag: bool
+	ratio: float32
+}
+
+var header = Header { tag: 1u8, length: 2, flag: true, ratio: 0.5 }`
+
+	machine := nativeMachine(t)
+	irGen := compile(t, input, withTarget(machine))
+
+	// fields are aligned like the fields of the C struct
+	// struct { uint8_t tag; int64_t length; bool flag; float ratio; }
+	headerType := irGen.Module.GetTypeByName("struct.Header")
+	data := machine.CreateTargetData()
+	require.Equal(t, uint64(0), data.ElementOffset(headerType, 0))
+	require.Equal(t, uint64(8), data.ElementOffset(headerType, 1))
+	require.Equal(t, uint64(16), data.ElementOffset(headerType, 2))
+	require.Equal(t, uint64(20), data.ElementOffset(headerType, 3))
+	require.Equal(t, uint64(24), data.TypeAllocSize(headerType))
+}
+
+func TestIRGenerator_Arrays(t *testing.T) {
+	input := `struct Point { x: int32, y: int32 }
+
+var table = [10, 20, 30, 40]
+var window = table[1..3]
+
+fn sum(values: []int32): int32 {
+	var total = 0
+	for i in 0..len(values) {
+		total += values[i]
+	}
+	return total
+}
+
+fn main(): int32 {
+	var points = [Point { x: 1, y: 2 }, Point { x: 3, y: 4 }]
+	points[1].y = 40
+	var a: [4]int32 = [1, 2, 3, 4]
+	var s = a[1..]
+	s[0] = 7
+	a[3] += 1
+	var flags = [true, false]
+	if flags[1] {
+		return 0
+	}
+	return sum(a[..]) + sum(window) + points[1].y + s[len(s) - 1]
+}`
+
+	irGen := compile(t, input)
+
+	ir := irGen.Module.String()
+	require.Contains(t, ir, "@table = global [4 x i32] [i32 10, i32 20, i32 30, i32 40]")
+	// slices of globals are constant, they point into the array
+	require.Contains(t, ir, windowGlobal)
+	require.Contains(t, ir, sumDefinition)
+	require.Contains(t, ir, `c"panic: index out of range [%lld] with length %lld at line %d, column %d\0A\00"`)
+	require.Contains(t, ir, "call void @llvm.trap()")
+
+	// s points into a, so a is [1, 7, 3, 5]
+	result := run(t, irGen)
+	require.Equal(t, uint64(111), result.Int(false))
+}
+
+func TestIRGenerator_DisableBoundsChecks(t *testing.T) {
+	input := `fn at(values: []int32, i: int64): int32 {
+	return values[i]
+}
+
+fn main(): int32 {
+	var a = [1, 2, 3]
+	var i = 1
+	return at(a[i..], 1) + a[i]
+}`
+
+	// the index, the slice bounds and the array index are checked
+	checked := compile(t, input).Module.String()
+	require.Equal(t, 3, strings.Count(checked, "call void @llvm.trap()"))
+	// values[i] is at line 2, column 14
+	require.Regexp(t, `@dprintf\(i32 2, .*@lotus\.index\.oob.*, i32 2, i32 14\)`, checked)
+	require.Contains(t, checked, "@lotus.slice.oob")
+
+	unchecked := compile(t, input, (*llvm.IRGenerator).DisableBoundsChecks).Module.String()
+	require.NotContains(t, unchecked, "@llvm.trap")
+	require.NotContains(t, unchecked, "@dprintf")
+	require.NotContains(t, unchecked, "oob")
+}
+
+func TestIRGenerator_LenIndexIsChecked(t *testing.T) {
+	input := `fn main(): int32 {
+	var a = [1, 2, 3]
+	var s = a[0..len(a) + 1]
+	return a[len(a)] + a[1] + len(a[1..2])
+}`
+
+	// only the literal index and bounds were checked by the parser
+	ir := compile(t, input).Module.String()
+	require.Equal(t, 2, strings.Count(ir, "call void @llvm.trap()"))
+	require.Contains(t, ir, "@lotus.index.oob")
+	require.Contains(t, ir, "@lotus.slice.oob")
+}
+
+func TestIRGenerator_ScopesAndParameters(t *testing.T) {
+	input := `fn scale(value: int32, factor: int32): int32 {
+	var result = value * factor
+	if factor > 2 {
+		var result = 0
+		factor = 0
+		result = result + 1
+	}
+	return result + factor
+}
+
+fn main(): int32 {
+	var x = 1
+	if x == 1 {
+		var x = 40
+		x = x + 2
+	}
+	return x + scale(3, 4) * 10
+}`
+
+	irGen := compile(t, input)
+
+	// the shadowed variables keep their values after the blocks
+	result := run(t, irGen)
+	require.Equal(t, uint64(1+12*10), result.Int(false))
+}
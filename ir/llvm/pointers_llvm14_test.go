@@ -0,0 +1,10 @@
+//go:build llvm14
+
+package llvm_test
+
+// the IR expected for the slices of TestIRGenerator_Arrays, LLVM 14
+// prints the type pointed to by every pointer
+const (
+	windowGlobal  = "@window = global { i32, i32* } { i32 2, i32* getelementptr inbounds ([4 x i32], [4 x i32]* @table, i64 0, i64 1) }"
+	sumDefinition = "define i32 @sum({ i32, i32* } %values)"
+)
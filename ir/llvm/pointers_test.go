@@ -0,0 +1,10 @@
+//go:build !llvm14
+
+package llvm_test
+
+// the IR expected for the slices of TestIRGenerator_Arrays, LLVM 15
+// and later only have opaque pointers
+const (
+	windowGlobal  = "@window = global { i32, ptr } { i32 2, ptr getelementptr inbounds ([4 x i32], ptr @table, i64 0, i64 1) }"
+	sumDefinition = "define i32 @sum({ i32, ptr } %values)"
+)
@@ -0,0 +1,72 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/EclesioMeloJunior/lotus/parser"
+	"tinygo.org/x/go-llvm"
+)
+
+// structType returns the named LLVM struct of a struct type, created on
+// first use. Fields keep their declaration order and are not packed, so
+// the layout given by the target data is the layout of the C struct.
+func (gen *IRGenerator) structType(rawType parser.Type) llvm.Type {
+	if typ, ok := gen.structs[rawType]; ok {
+		return typ
+	}
+
+	stmt := gen.types.Struct(rawType)
+	if stmt == nil {
+		panic(fmt.Sprintf("type %v not supported", rawType))
+	}
+
+	typ := gen.context.StructCreateNamed("struct." + stmt.Name)
+	gen.structs[rawType] = typ
+
+	fields := make([]llvm.Type, len(stmt.Fields))
+	for idx, field := range stmt.Fields {
+		fields[idx] = gen.memoryType(field.Type)
+	}
+	typ.StructSetBody(fields, false)
+	return typ
+}
+
+// generateStructLiteral builds the struct value field by field, the
+// value is a constant when every field is.
+func (gen *IRGenerator) generateStructLiteral(expr *parser.StructLiteral, fnName string) llvm.Value {
+	stmt := gen.types.Struct(expr.Type)
+
+	value := llvm.Undef(gen.structType(expr.Type))
+	for idx, field := range expr.Fields {
+		fieldValue := gen.toMemory(stmt.Fields[idx].Type, gen.generateExpression(field, fnName))
+		value = gen.builder.CreateInsertValue(value, fieldValue, idx, "")
+	}
+	return value
+}
+
+// generateFieldAccess extracts the field from the struct value.
+func (gen *IRGenerator) generateFieldAccess(expr *parser.FieldAccess, fnName string) llvm.Value {
+	value := gen.generateExpression(expr.Value, fnName)
+	field := gen.builder.CreateExtractValue(value, expr.Index, expr.Field)
+	return gen.fromMemory(expr.Type, field)
+}
+
+// generateAssignFieldStatement stores the value in the field of the
+// struct variable, top-level assignments run in the static initializer.
+func (gen *IRGenerator) generateAssignFieldStatement(stmt *parser.AssignFieldStatement, fnName string) {
+	if fnName == "" {
+		gen.staticInit(func() {
+			gen.store(stmt.Target.Type, gen.generateExpression(stmt.Value, staticInitName), gen.fieldPointer(stmt.Target, staticInitName))
+		})
+		return
+	}
+
+	gen.store(stmt.Target.Type, gen.generateExpression(stmt.Value, fnName), gen.fieldPointer(stmt.Target, fnName))
+}
+
+// fieldPointer returns the address of the field in the storage of the
+// struct value.
+func (gen *IRGenerator) fieldPointer(access *parser.FieldAccess, fnName string) llvm.Value {
+	structType := gen.structType(parser.TypeOf(access.Value))
+	return gen.builder.CreateStructGEP(structType, gen.addressOf(access.Value, fnName), access.Index, access.Field+".ptr")
+}
@@ -0,0 +1,187 @@
+package lexer
+
+import (
+	"slices"
+	"sort"
+)
+
+// State is a snapshot of the lexer taken at the start of a line,
+// scanning can be resumed from it with NewLexerFromState.
+type State struct {
+	Offset int
+	Line   int
+
+	doc         []string
+	lastNewline bool
+	insertSemi  bool
+}
+
+// equal reports whether scanning from both states yields the same
+// tokens, given the source after them is the same. Lines may differ.
+func (s State) equal(other State) bool {
+	return s.lastNewline == other.lastNewline &&
+		s.insertSemi == other.insertSemi &&
+		slices.Equal(s.doc, other.doc)
+}
+
+func (l *Lexer) recordState() {
+	// unterminated tokens reaching the end of the input would
+	// be extended by text appended there, so no state is taken
+	if l.offset >= len(l.src) {
+		return
+	}
+
+	if n := len(l.states); n > 0 && l.states[n-1].Offset == l.offset {
+		return
+	}
+
+	l.states = append(l.states, State{
+		Offset:      l.offset,
+		Line:        l.line,
+		doc:         slices.Clone(l.doc),
+		lastNewline: l.lastNewline,
+		insertSemi:  l.insertSemi,
+	})
+}
+
+// NewLexerFromState returns a lexer scanning src from a state taken
+// on a previous scan, the source before the state offset is skipped.
+func NewLexerFromState(src []byte, state State) *Lexer {
+	l := NewLexerFromBytes(src)
+	l.offset = state.Offset
+	l.line = state.Line
+	l.doc = slices.Clone(state.doc)
+	l.lastNewline = state.lastNewline
+	l.insertSemi = state.insertSemi
+	return l
+}
+
+// TokenList holds the tokens of a source along with the lexer state
+// at the start of its lines, so it can be updated after an edit by
+// Relex without scanning the whole source again.
+type TokenList struct {
+	Tokens []Token
+	// States are ordered by offset, lines starting inside a
+	// token (e.g. a multi line string) have no state.
+	States []State
+}
+
+// Edit describes a change to the source, the bytes in [Start, OldEnd)
+// of the previous source were replaced by [Start, NewEnd) of the new one.
+type Edit struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+// Tokenize scans the whole src, keeping the line states needed by Relex.
+func Tokenize(src []byte) *TokenList {
+	l := NewLexerFromBytes(src)
+	l.recordStates = true
+	return l.scan(nil)
+}
+
+// scan collects the tokens until the end of the input, stopping
+// earlier when resync returns true for a recorded state.
+func (l *Lexer) scan(resync func(State) bool) *TokenList {
+	list := &TokenList{}
+	checked := len(l.states)
+	for {
+		tok := l.next()
+		if resync != nil && len(l.states) > checked {
+			checked = len(l.states)
+			// the token was scanned after the state matched
+			// and is already part of the previous list
+			if resync(l.states[checked-1]) {
+				break
+			}
+		}
+
+		list.Tokens = append(list.Tokens, tok)
+		if tok.Type == EOF {
+			break
+		}
+	}
+	list.States = l.states
+	return list
+}
+
+// Relex updates prev, the tokens of the source before edit, to the
+// tokens of src. Scanning restarts at the last line start before the
+// edit and stops as soon as the lexer reaches a line start after it in
+// the same state as the previous scan, the remaining tokens are reused
+// with their positions shifted. Reused literals still refer to the
+// previous source, which must be kept unmodified.
+func Relex(prev *TokenList, src []byte, edit Edit) *TokenList {
+	// the last state at or before the edit
+	idx := sort.Search(len(prev.States), func(i int) bool {
+		return prev.States[i].Offset > edit.Start
+	}) - 1
+	if idx < 0 {
+		return Tokenize(src)
+	}
+
+	start := prev.States[idx]
+	l := NewLexerFromState(src, start)
+	l.recordStates = true
+
+	delta := edit.NewEnd - edit.OldEnd
+	resynced := false
+	var resumeAt, lineDelta int
+
+	resync := func(state State) bool {
+		if state.Offset < edit.NewEnd {
+			return false
+		}
+
+		oldOffset := state.Offset - delta
+		i, found := sort.Find(len(prev.States), func(i int) int {
+			return oldOffset - prev.States[i].Offset
+		})
+		if !found || !state.equal(prev.States[i]) {
+			return false
+		}
+
+		resynced = true
+		resumeAt = i
+		lineDelta = state.Line - prev.States[i].Line
+		return true
+	}
+
+	relexed := l.scan(resync)
+
+	// tokens and states before the restart point are kept as is
+	first := sort.Search(len(prev.Tokens), func(i int) bool {
+		return prev.Tokens[i].Start >= start.Offset
+	})
+
+	list := &TokenList{
+		Tokens: slices.Concat(prev.Tokens[:first], relexed.Tokens),
+		States: slices.Concat(prev.States[:idx], relexed.States),
+	}
+
+	if !resynced {
+		return list
+	}
+
+	oldOffset := prev.States[resumeAt].Offset
+	rest := sort.Search(len(prev.Tokens), func(i int) bool {
+		return prev.Tokens[i].Start >= oldOffset
+	})
+
+	for _, tok := range prev.Tokens[rest:] {
+		tok.Start += delta
+		tok.End += delta
+		tok.Line += lineDelta
+		list.Tokens = append(list.Tokens, tok)
+	}
+
+	// the matching state was already recorded by the relex
+	for _, state := range prev.States[resumeAt+1:] {
+		state.Offset += delta
+		state.Line += lineDelta
+		list.States = append(list.States, state)
+	}
+
+	return list
+}
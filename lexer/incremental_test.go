@@ -0,0 +1,125 @@
+package lexer_test
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/EclesioMeloJunior/lotus/lexer"
+	"github.com/stretchr/testify/require"
+)
+
+const incrementalSource = `/// adds two numbers
+fn add(a: int32, b: int32): int32 {
+	/* a block
+	   comment */
+	var total = a +
+		b * 0xff
+	return total
+}
+
+fn main() {
+	var s = "a \"quoted\" string"
+	var r = ` + "`raw\nstring`" + `
+	var c = '\n'
+	add(1,
+		2)
+}
+`
+
+func applyEdit(src []byte, edit lexer.Edit, text []byte) []byte {
+	return slices.Concat(src[:edit.Start], text, src[edit.OldEnd:])
+}
+
+func TestRelexSingleEdit(t *testing.T) {
+	src := []byte(incrementalSource)
+	prev := lexer.Tokenize(src)
+
+	// rename `total` in its declaration
+	offset := len("/// adds two numbers\nfn add(a: int32, b: int32): int32 {\n\t/* a block\n\t   comment */\n\tvar ")
+	edit := lexer.Edit{Start: offset, OldEnd: offset + len("total"), NewEnd: offset + len("sum")}
+	updated := applyEdit(src, edit, []byte("sum"))
+
+	got := lexer.Relex(prev, updated, edit)
+	require.Equal(t, lexer.Tokenize(updated), got)
+
+	// the tokens after the edited line are reused, not scanned again
+	last := got.Tokens[len(got.Tokens)-3]
+	require.Equal(t, "}", last.Literal)
+	require.Equal(t,
+		unsafe.StringData(prev.Tokens[len(prev.Tokens)-3].Literal),
+		unsafe.StringData(last.Literal))
+}
+
+func TestRelexStateChanges(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		at    string
+		text  string
+	}{
+		"open block comment": {
+			input: "var x = 1\nvar y = 2\nvar z = 3\n",
+			at:    "var y",
+			text:  "/* ",
+		},
+		"open raw string": {
+			input: "var x = 1\nvar y = 2\nvar z = 3\n",
+			at:    "var y",
+			text:  "`",
+		},
+		"trailing operator removes the semicolon": {
+			input: "var x = 1\n2\nvar z = 3\n",
+			at:    "\n2",
+			text:  " +",
+		},
+		"doc comment attached to the next line": {
+			input: "var x = 1\n\nvar y = 2\n",
+			at:    "\nvar y",
+			text:  "/// doc",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			src := []byte(tt.input)
+			offset := strings.Index(tt.input, tt.at)
+
+			edit := lexer.Edit{Start: offset, OldEnd: offset, NewEnd: offset + len(tt.text)}
+			updated := applyEdit(src, edit, []byte(tt.text))
+
+			got := lexer.Relex(lexer.Tokenize(src), updated, edit)
+			require.Equal(t, lexer.Tokenize(updated), got)
+		})
+	}
+}
+
+func TestRelexRandomEdits(t *testing.T) {
+	fragments := []string{
+		"", "x", "var", " ", "\n", "\n\n", "+", "1_000", "3.14", "(", ")", "{", "}",
+		"//", "/// doc\n", "/*", "*/", "\"", "\\", "`", "'", "'a'", ",", "é", "\u200b",
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	src := []byte(incrementalSource)
+	list := lexer.Tokenize(src)
+
+	for i := 0; i < 2000; i++ {
+		start := rng.Intn(len(src) + 1)
+		oldEnd := min(start+rng.Intn(8), len(src))
+		text := []byte(fragments[rng.Intn(len(fragments))])
+
+		edit := lexer.Edit{Start: start, OldEnd: oldEnd, NewEnd: start + len(text)}
+		src = applyEdit(src, edit, text)
+
+		list = lexer.Relex(list, src, edit)
+		require.Equal(t, lexer.Tokenize(src), list, "edit %d: %+v %q", i, edit, text)
+
+		// keep the source from growing without bounds
+		if len(src) > 4*len(incrementalSource) {
+			src = []byte(incrementalSource)
+			list = lexer.Tokenize(src)
+		}
+	}
+}
@@ -1,13 +1,65 @@
 package lexer
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"iter"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+	"unsafe"
 )
 
+var (
+	ErrUnterminatedString  = errors.New("unterminated string literal")
+	ErrUnterminatedComment = errors.New("unterminated block comment")
+	ErrInvalidEscape       = errors.New("invalid escape sequence")
+	ErrInvalidChar         = errors.New("invalid character literal")
+	ErrIllegalCharacter    = errors.New("illegal character")
+	ErrInvalidNumber       = errors.New("invalid number literal")
+
+	ErrInvisibleCharacter   = errors.New("invisible character")
+	ErrConfusableIdentifier = errors.New("confusable identifier")
+)
+
+// ErrLexer represents a scanning error with line and column information.
+type ErrLexer struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ErrLexer) Error() string {
+	return fmt.Sprintf("Error at line %d, column %d: %s", e.Line, e.Column, e.Err.Error())
+}
+
+func (e *ErrLexer) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics is a sink for the errors found while scanning, the lexer
+// reports to it and keeps going so a single run finds every problem.
+type Diagnostics struct {
+	errs []*ErrLexer
+}
+
+// Report records a new error.
+func (d *Diagnostics) Report(err *ErrLexer) {
+	d.errs = append(d.errs, err)
+}
+
+// Errors returns the recorded errors in the order they were found.
+func (d *Diagnostics) Errors() []*ErrLexer {
+	return d.errs
+}
+
+// HasErrors reports if any error was recorded.
+func (d *Diagnostics) HasErrors() bool {
+	return len(d.errs) > 0
+}
+
 // TokenType represents the type of token.
 type TokenType int
 
@@ -34,11 +86,49 @@ const (
 	RPAREN
 	FLOAT
 	SEMICOLON
-	NEXTLINE
 	RETURN
 	COMMA
 	COLON
 	RAWTYPE
+	EQ
+	NOT_EQ
+	LT
+	LT_EQ
+	GT
+	GT_EQ
+	AND
+	OR
+	BANG
+	PERCENT
+	AMPERSAND
+	PIPE
+	CARET
+	SHL
+	SHR
+	CHAR
+	ELSE
+	WHILE
+	LOOP
+	FOR
+	IN
+	DOTDOT
+	DOTDOT_EQ
+	TRUE
+	FALSE
+	TILDE
+	PLUS_ASSIGN
+	MINUS_ASSIGN
+	STAR_ASSIGN
+	SLASH_ASSIGN
+	PERCENT_ASSIGN
+	INC
+	DEC
+	CONST
+	AS
+	STRUCT
+	DOT
+	LBRACKET
+	RBRACKET
 )
 
 func (t *TokenType) String() string {
@@ -85,8 +175,6 @@ func (t *TokenType) String() string {
 		return "FLOAT"
 	case SEMICOLON:
 		return "SEMICOLON"
-	case NEXTLINE:
-		return "NEXTLINE"
 	case RETURN:
 		return "RETURN"
 	case COMMA:
@@ -97,6 +185,84 @@ func (t *TokenType) String() string {
 		return "RAWTYPE"
 	case STRING:
 		return "STRING"
+	case EQ:
+		return "EQ"
+	case NOT_EQ:
+		return "NOT_EQ"
+	case LT:
+		return "LT"
+	case LT_EQ:
+		return "LT_EQ"
+	case GT:
+		return "GT"
+	case GT_EQ:
+		return "GT_EQ"
+	case AND:
+		return "AND"
+	case OR:
+		return "OR"
+	case BANG:
+		return "BANG"
+	case PERCENT:
+		return "PERCENT"
+	case AMPERSAND:
+		return "AMPERSAND"
+	case PIPE:
+		return "PIPE"
+	case CARET:
+		return "CARET"
+	case SHL:
+		return "SHL"
+	case SHR:
+		return "SHR"
+	case CHAR:
+		return "CHAR"
+	case ELSE:
+		return "ELSE"
+	case WHILE:
+		return "WHILE"
+	case LOOP:
+		return "LOOP"
+	case FOR:
+		return "FOR"
+	case IN:
+		return "IN"
+	case DOTDOT:
+		return "DOTDOT"
+	case DOTDOT_EQ:
+		return "DOTDOT_EQ"
+	case TRUE:
+		return "TRUE"
+	case FALSE:
+		return "FALSE"
+	case TILDE:
+		return "TILDE"
+	case PLUS_ASSIGN:
+		return "PLUS_ASSIGN"
+	case MINUS_ASSIGN:
+		return "MINUS_ASSIGN"
+	case STAR_ASSIGN:
+		return "STAR_ASSIGN"
+	case SLASH_ASSIGN:
+		return "SLASH_ASSIGN"
+	case PERCENT_ASSIGN:
+		return "PERCENT_ASSIGN"
+	case INC:
+		return "INC"
+	case DEC:
+		return "DEC"
+	case CONST:
+		return "CONST"
+	case AS:
+		return "AS"
+	case STRUCT:
+		return "STRUCT"
+	case DOT:
+		return "DOT"
+	case LBRACKET:
+		return "LBRACKET"
+	case RBRACKET:
+		return "RBRACKET"
 	default:
 		return "UNKNOWN"
 	}
@@ -104,13 +270,36 @@ func (t *TokenType) String() string {
 
 var Keywords = map[string]TokenType{
 	"var":      VAR,
+	"const":    CONST,
+	"as":       AS,
+	"struct":   STRUCT,
 	"fn":       FN,
 	"continue": CONTINUE,
 	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"loop":     LOOP,
+	"for":      FOR,
+	"in":       IN,
+	"true":     TRUE,
+	"false":    FALSE,
 	"break":    BREAK,
 	"return":   RETURN,
+	"int8":     RAWTYPE,
+	"int16":    RAWTYPE,
 	"int32":    RAWTYPE,
+	"int64":    RAWTYPE,
+	"uint8":    RAWTYPE,
+	"uint16":   RAWTYPE,
+	"uint32":   RAWTYPE,
+	"uint64":   RAWTYPE,
+	"isize":    RAWTYPE,
+	"usize":    RAWTYPE,
+	"float32":  RAWTYPE,
+	"float64":  RAWTYPE,
 	"string":   RAWTYPE,
+	"char":     RAWTYPE,
+	"bool":     RAWTYPE,
 }
 
 // Token represents a lexical token.
@@ -119,201 +308,775 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+
+	// Start and End are the byte offsets of the token in
+	// the input, the token spans input[Start:End]
+	Start int
+	End   int
+
+	// Doc holds the `///` doc comment lines that directly
+	// precede the token, joined by new lines
+	Doc string
 }
 
 func (t *Token) String() string {
 	return t.Literal
 }
 
-// Lexer represents a lexical scanner.
+// Lexer represents a lexical scanner. The input is scanned in place
+// and token literals are views over it instead of copies, only literals
+// that differ from the input (e.g. strings with escapes) are allocated.
 type Lexer struct {
-	r      *bufio.Reader
+	src []byte
+	// text is src viewed as a string, literals are slices of it
+	text string
+
+	offset int
 	line   int
 	column int
+
+	// doc accumulates `///` comment lines until the next
+	// token that is not a statement terminator
+	doc         []string
+	lastNewline bool
+
+	// insertSemi is set when the last token can end a statement,
+	// the next new line (or the end of the input) is then emitted
+	// as a SEMICOLON.
+	insertSemi bool
+
+	// states records the lexer state at every line start reached
+	// between tokens, only when recordStates is set
+	states       []State
+	recordStates bool
+
+	diagnostics *Diagnostics
 }
 
-// NewLexer returns a new instance of Lexer.
+// NewLexer returns a new instance of Lexer. Readers exposing their
+// contents through Bytes, like source.SourceFile, are scanned in place
+// otherwise the reader is fully read before scanning.
 func NewLexer(r io.Reader) *Lexer {
-	return &Lexer{r: bufio.NewReader(r), line: 1, column: 0}
+	if contents, ok := r.(interface{ Bytes() []byte }); ok {
+		return NewLexerFromBytes(contents.Bytes())
+	}
+
+	src, err := io.ReadAll(r)
+	l := NewLexerFromBytes(src)
+	if err != nil {
+		l.report(1, 0, err)
+	}
+	return l
+}
+
+// NewLexerFromBytes returns a new instance of Lexer scanning src in place,
+// src must not be modified while the lexer or its tokens are in use.
+func NewLexerFromBytes(src []byte) *Lexer {
+	return &Lexer{
+		src:         src,
+		text:        unsafe.String(unsafe.SliceData(src), len(src)),
+		line:        1,
+		column:      0,
+		diagnostics: &Diagnostics{},
+	}
+}
+
+// Diagnostics returns the sink holding the errors found while scanning.
+func (l *Lexer) Diagnostics() *Diagnostics {
+	return l.diagnostics
 }
 
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() iter.Seq[Token] {
 	return func(yield func(Token) bool) {
 		for {
-			ch := l.read()
-
-			var tok Token
-			tok.Line = l.line
-			tok.Column = l.column
-
-			switch {
-			case ch == '\n':
-				tok = Token{Type: NEXTLINE, Literal: string(ch), Line: l.line, Column: l.column}
-				l.line++
-				l.column = 0
-			case isWhitespace(ch): // skip whitespace
-				continue
-			case isLetter(ch):
-				l.unread()
-				tok = l.readIdent()
-				tok.Line = l.line
+			tok := l.next()
+			if !yield(tok) {
+				return
+			}
 
-				tok.Column = l.column - len(tok.Literal)
+			if tok.Type == EOF {
+				return
+			}
+		}
+	}
+}
 
-				if _, isKeyword := Keywords[tok.Literal]; isKeyword {
-					tok.Type = Keywords[tok.Literal]
+// next scans the next token, skipping whitespaces, comments
+// and reporting illegal characters.
+func (l *Lexer) next() Token {
+	for {
+		line, column, start := l.line, l.column, l.offset
+		if l.recordStates && column == 0 {
+			l.recordState()
+		}
+
+		var tok Token
+		switch ch := l.peekByte(0); {
+		case l.offset >= len(l.src):
+			if l.insertSemi {
+				tok = Token{Type: SEMICOLON, Literal: ""}
+				break
+			}
+			tok = Token{Type: EOF, Literal: ""}
+		case ch == '\n':
+			l.advance(1)
+
+			// a blank line detaches the doc comment from what follows
+			if l.lastNewline {
+				l.doc = nil
+			}
+
+			if !l.insertSemi {
+				l.lastNewline = true
+				continue
+			}
+			tok = Token{Type: SEMICOLON, Literal: l.text[start:l.offset]}
+		case isWhitespace(ch): // skip whitespace
+			l.advance(1)
+			continue
+		case isASCIIIdentStart(ch):
+			tok = l.readIdent(line, column)
+		case isDigit(ch):
+			tok = l.readNumber(line, column)
+		case ch == '/' && l.peekByte(1) == '/':
+			l.readLineComment()
+			continue
+		case ch == '/' && l.peekByte(1) == '*':
+			if l.readBlockComment() {
+				// a comment spanning lines acts like a new line
+				if l.insertSemi && l.line > line {
+					tok = Token{Type: SEMICOLON, Literal: "\n"}
+					break
 				}
-			case isDigit(ch):
-				l.unread()
-				tok = l.readNumber()
-				tok.Line = l.line
-				tok.Column = l.column - len(tok.Literal)
-			case ch == '=':
-				tok = Token{Type: ASSIGN, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '+':
-				tok = Token{Type: PLUS, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '-':
-				tok = Token{Type: MINUS, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '*':
-				tok = Token{Type: STAR, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '/':
-				tok = Token{Type: SLASH, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '{':
-				tok = Token{Type: LBRACE, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '}':
-				tok = Token{Type: RBRACE, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '(':
-				tok = Token{Type: LPAREN, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == ')':
-				tok = Token{Type: RPAREN, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == ';':
-				tok = Token{Type: SEMICOLON, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == ',':
-				tok = Token{Type: COMMA, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == ':':
-				tok = Token{Type: COLON, Literal: string(ch), Line: l.line, Column: l.column - 1}
-			case ch == '"':
-				tok = l.readString()
-				tok.Line = l.line
-
-				tok.Column = l.column - len(tok.Literal)
-			case ch == 0:
-				tok = Token{Type: EOF, Literal: "", Line: 0, Column: 0}
-			default:
-				tok = Token{Type: ILLEGAL, Literal: string(ch), Line: 0, Column: 0}
+				continue
 			}
 
-			if !yield(tok) {
-				return
+			l.report(line, column, ErrUnterminatedComment)
+			tok = Token{Type: ILLEGAL, Literal: l.text[start:l.offset]}
+		case ch == '"':
+			tok = l.readString(line, column)
+		case ch == '`':
+			tok = l.readRawString(line, column)
+		case ch == '\'':
+			tok = l.readChar(line, column)
+		case punctuation[ch] != ILLEGAL:
+			tok = Token{Type: l.readOperator()}
+			tok.Literal = l.text[start:l.offset]
+		case ch >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(l.src[l.offset:])
+			if isIdentStart(r) {
+				tok = l.readIdent(line, column)
+				break
 			}
 
-			if tok.Type == EOF {
-				return
+			l.advance(size)
+			if isInvisible(r) {
+				l.report(line, column, fmt.Errorf("%w: %U", ErrInvisibleCharacter, r))
+			} else {
+				l.report(line, column, fmt.Errorf("%w: %q", ErrIllegalCharacter, r))
 			}
+			continue
+		default:
+			// record the character and keep scanning, so the parser
+			// only sees valid tokens and every bad character is reported
+			l.advance(1)
+			l.report(line, column, fmt.Errorf("%w: %q", ErrIllegalCharacter, ch))
+			continue
+		}
+
+		tok.Line = line
+		tok.Column = column
+		tok.Start = start
+		tok.End = l.offset
+
+		l.lastNewline = tok.Literal == "\n"
+		if tok.Type != SEMICOLON && len(l.doc) > 0 {
+			tok.Doc = strings.Join(l.doc, "\n")
+			l.doc = nil
 		}
+		l.insertSemi = endsStatement(tok.Type)
+
+		return tok
 	}
 }
 
-func (l *Lexer) read() rune {
-	ch, _, err := l.r.ReadRune()
-	if err != nil {
-		return 0
+// endsStatement reports whether a token can be the last one of a
+// statement, a new line after it is turned into a SEMICOLON. This
+// follows Go, so an expression can continue on the next line after
+// an operator, a comma or an opening parenthesis or bracket.
+func endsStatement(t TokenType) bool {
+	switch t {
+	case IDENT, INT, FLOAT, STRING, CHAR, TRUE, FALSE, RAWTYPE,
+		RETURN, BREAK, CONTINUE, RPAREN, RBRACE, RBRACKET, INC, DEC:
+		return true
 	}
-	l.column++
-	return ch
+	return false
 }
 
-func (l *Lexer) unread() {
-	_ = l.r.UnreadRune()
-	l.column--
+func (l *Lexer) report(line, column int, err error) {
+	l.diagnostics.Report(&ErrLexer{Line: line, Column: column, Err: err})
 }
 
-func (l *Lexer) readIdent() Token {
-	var buf bytes.Buffer
-	buf.WriteRune(l.read())
-	for {
-		if ch := l.read(); isLetter(ch) || isDigit(ch) {
-			buf.WriteRune(ch)
-		} else {
-			l.unread()
-			break
+// peekByte returns the byte n positions after the cursor
+// without consuming it, 0 is returned past the end of the input.
+func (l *Lexer) peekByte(n int) byte {
+	if l.offset+n < len(l.src) {
+		return l.src[l.offset+n]
+	}
+	return 0
+}
+
+// advance consumes n bytes keeping the line and column up to date,
+// columns count runes so utf-8 continuation bytes are not counted.
+func (l *Lexer) advance(n int) {
+	for _, b := range l.src[l.offset : l.offset+n] {
+		switch {
+		case b == '\n':
+			l.line++
+			l.column = 0
+		case b&0xC0 != 0x80:
+			l.column++
 		}
 	}
-	return Token{Type: IDENT, Literal: buf.String()}
+	l.offset += n
 }
 
-func (l *Lexer) readString() Token {
-	var buf bytes.Buffer
-	buf.WriteRune(l.read())
-	for {
-		if ch := l.read(); ch != '"' {
-			buf.WriteRune(ch)
-		} else {
+// punctuation maps the operators and delimiters first byte to their
+// single character token, bytes that are not punctuation map to ILLEGAL.
+var punctuation = [256]TokenType{
+	'=': ASSIGN, '!': BANG, '<': LT, '>': GT, '&': AMPERSAND, '|': PIPE,
+	'^': CARET, '%': PERCENT, '+': PLUS, '-': MINUS, '*': STAR, '/': SLASH,
+	'{': LBRACE, '}': RBRACE, '(': LPAREN, ')': RPAREN, ';': SEMICOLON,
+	',': COMMA, ':': COLON, '~': TILDE, '.': DOT, '[': LBRACKET, ']': RBRACKET,
+}
+
+// compoundAssignments maps the operator first byte of compound
+// assignments, e.g: +=, to their token.
+var compoundAssignments = [256]TokenType{
+	'+': PLUS_ASSIGN, '-': MINUS_ASSIGN, '*': STAR_ASSIGN,
+	'/': SLASH_ASSIGN, '%': PERCENT_ASSIGN,
+}
+
+// readOperator consumes the operator at the cursor, two
+// character operators take precedence over single ones.
+func (l *Lexer) readOperator() TokenType {
+	first, second := l.src[l.offset], l.peekByte(1)
+
+	double := ILLEGAL
+	switch {
+	case first == '=' && second == '=':
+		double = EQ
+	case first == '!' && second == '=':
+		double = NOT_EQ
+	case first == '<' && second == '=':
+		double = LT_EQ
+	case first == '<' && second == '<':
+		double = SHL
+	case first == '>' && second == '=':
+		double = GT_EQ
+	case first == '>' && second == '>':
+		double = SHR
+	case first == '&' && second == '&':
+		double = AND
+	case first == '|' && second == '|':
+		double = OR
+	case first == '+' && second == '+':
+		double = INC
+	case first == '-' && second == '-':
+		double = DEC
+	case second == '=' && compoundAssignments[first] != ILLEGAL:
+		double = compoundAssignments[first]
+	case first == '.' && second == '.':
+		if l.peekByte(2) == '=' {
+			l.advance(3)
+			return DOTDOT_EQ
+		}
+		double = DOTDOT
+	}
+
+	if double != ILLEGAL {
+		l.advance(2)
+		return double
+	}
+
+	l.advance(1)
+	return punctuation[first]
+}
+
+// readLineComment consumes a `//` comment up to, but not including,
+// the new line. Comments starting with exactly `///` are doc comments
+// and are kept to be attached to the next token.
+func (l *Lexer) readLineComment() {
+	end := len(l.src)
+	if idx := bytes.IndexByte(l.src[l.offset:], '\n'); idx >= 0 {
+		end = l.offset + idx
+	}
+
+	comment := l.text[l.offset:end]
+	l.advance(end - l.offset)
+
+	l.lastNewline = false
+	if strings.HasPrefix(comment, "///") && !strings.HasPrefix(comment, "////") {
+		doc := strings.TrimPrefix(strings.TrimRight(comment[3:], "\r"), " ")
+		l.doc = append(l.doc, doc)
+	}
+}
+
+// readBlockComment consumes a `/* */` comment, block comments
+// can be nested. It returns false if the input ends before the
+// comment is closed.
+func (l *Lexer) readBlockComment() bool {
+	l.advance(2) // '/*'
+
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.offset >= len(l.src):
+			return false
+		case l.src[l.offset] == '/' && l.peekByte(1) == '*':
+			l.advance(2)
+			depth++
+		case l.src[l.offset] == '*' && l.peekByte(1) == '/':
+			l.advance(2)
+			depth--
+		default:
+			l.advance(1)
+		}
+	}
+
+	return true
+}
+
+// readIdent reads an identifier or keyword, the literal is the NFC
+// normalized form of the identifier. Identifiers mixing latin letters
+// with look-alike letters from other scripts are reported.
+func (l *Lexer) readIdent(line, column int) Token {
+	start := l.offset
+	ascii := l.skipIdent()
+	ident := l.text[start:l.offset]
+
+	if !ascii {
+		ident = normalizeIdent(ident)
+		if err := checkConfusable(ident); err != nil {
+			l.report(line, column, err)
+		}
+	}
+
+	if tokType, isKeyword := Keywords[ident]; isKeyword {
+		return Token{Type: tokType, Literal: ident}
+	}
+
+	return Token{Type: IDENT, Literal: ident}
+}
+
+// skipIdent consumes identifier characters, it
+// reports if all the consumed characters were ascii.
+func (l *Lexer) skipIdent() bool {
+	ascii := true
+	for l.offset < len(l.src) {
+		if ch := l.src[l.offset]; ch < utf8.RuneSelf {
+			if !isASCIIIdentStart(ch) && !isDigit(ch) {
+				break
+			}
+
+			l.offset++
+			l.column++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(l.src[l.offset:])
+		if !isIdentContinue(r) {
 			break
 		}
+
+		// zero width joiners continue identifiers but make
+		// two different names look the same
+		if isInvisible(r) {
+			l.report(l.line, l.column, fmt.Errorf("%w: %U", ErrInvisibleCharacter, r))
+		}
+
+		ascii = false
+		l.offset += size
+		l.column++
 	}
-	return Token{Type: STRING, Literal: buf.String()}
+
+	return ascii
 }
 
-func (l *Lexer) readNumber() Token {
-	var buf bytes.Buffer
-	buf.WriteRune(l.read())
+// readString reads a double quoted string, decoding its escape
+// sequences. Strings cannot span multiple lines, on error an ILLEGAL
+// token is returned and the error is recorded in the lexer.
+func (l *Lexer) readString(line, column int) Token {
+	tokStart := l.offset
+	l.advance(1) // opening quote
+
+	// strings without escapes are views over the input
+	start, end := l.offset, l.offset
+	for end < len(l.src) && l.src[end] != '"' && l.src[end] != '\\' && l.src[end] != '\n' {
+		end++
+	}
+
+	if end < len(l.src) && l.src[end] == '"' {
+		l.advance(end + 1 - l.offset)
+		return Token{Type: STRING, Literal: l.text[start:end]}
+	}
+
+	l.advance(end - l.offset)
+	buf := append([]byte(nil), l.src[start:end]...)
+
+	var escapeErr *ErrLexer
 	for {
-		if ch := l.read(); isDigit(ch) {
-			buf.WriteRune(ch)
-		} else {
-			l.unread()
-			break
+		if l.offset >= len(l.src) || l.src[l.offset] == '\n' {
+			l.report(line, column, ErrUnterminatedString)
+			return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+		}
+
+		switch ch := l.src[l.offset]; ch {
+		case '"':
+			l.advance(1)
+			if escapeErr != nil {
+				l.diagnostics.Report(escapeErr)
+				return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+			}
+
+			return Token{Type: STRING, Literal: string(buf)}
+		case '\\':
+			escLine, escColumn := l.line, l.column
+			decoded, err := l.readEscape('"')
+			if err != nil {
+				// keep scanning until the closing quote so the
+				// rest of the string is not lexed as code
+				if escapeErr == nil {
+					escapeErr = &ErrLexer{Line: escLine, Column: escColumn, Err: err}
+				}
+				continue
+			}
+			buf = utf8.AppendRune(buf, decoded)
+		default:
+			buf = append(buf, ch)
+			l.advance(1)
 		}
 	}
+}
 
-	if l.peek() == '.' {
-		buf.WriteRune(l.read())
+// readChar reads a single quoted character literal, it accepts
+// the same escape sequences as strings.
+func (l *Lexer) readChar(line, column int) Token {
+	tokStart := l.offset
+	l.advance(1) // opening quote
 
-		for {
-			if ch := l.read(); isDigit(ch) {
-				buf.WriteRune(ch)
-			} else {
-				l.unread()
-				break
+	var value rune
+	switch ch := l.peekByte(0); {
+	case l.offset >= len(l.src) || ch == '\n':
+		l.report(line, column, fmt.Errorf("%w: unterminated", ErrInvalidChar))
+		return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+	case ch == '\'':
+		l.advance(1)
+		l.report(line, column, fmt.Errorf("%w: empty", ErrInvalidChar))
+		return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+	case ch == '\\':
+		escLine, escColumn := l.line, l.column
+		decoded, err := l.readEscape('\'')
+		if err != nil {
+			l.skipCharLiteral()
+			l.report(escLine, escColumn, err)
+			return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+		}
+		value = decoded
+	default:
+		r, size := utf8.DecodeRune(l.src[l.offset:])
+		l.advance(size)
+		value = r
+	}
+
+	if l.peekByte(0) != '\'' {
+		l.skipCharLiteral()
+		l.report(line, column, fmt.Errorf("%w: expected a single character", ErrInvalidChar))
+		return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+	}
+	l.advance(1)
+
+	if l.src[tokStart+1] != '\\' {
+		return Token{Type: CHAR, Literal: l.text[tokStart+1 : l.offset-1]}
+	}
+	return Token{Type: CHAR, Literal: string(value)}
+}
+
+// skipCharLiteral consumes a malformed character literal up to the
+// closing quote, the end of the line or the end of the input.
+func (l *Lexer) skipCharLiteral() {
+	for l.offset < len(l.src) {
+		switch l.src[l.offset] {
+		case '\'':
+			l.advance(1)
+			return
+		case '\n':
+			return
+		}
+		l.advance(1)
+	}
+}
+
+// readEscape decodes the escape sequence starting at the backslash,
+// quote is the delimiter of the literal being read and can be escaped.
+func (l *Lexer) readEscape(quote byte) (rune, error) {
+	l.advance(1) // backslash
+
+	ch := l.peekByte(0)
+	if l.offset >= len(l.src) || ch == '\n' {
+		return 0, ErrInvalidEscape
+	}
+
+	switch ch {
+	case 'n':
+		l.advance(1)
+		return '\n', nil
+	case 't':
+		l.advance(1)
+		return '\t', nil
+	case 'r':
+		l.advance(1)
+		return '\r', nil
+	case '0':
+		l.advance(1)
+		return 0, nil
+	case '\\', quote:
+		l.advance(1)
+		return rune(ch), nil
+	case 'u':
+		l.advance(1)
+		return l.readUnicodeEscape()
+	default:
+		r, size := utf8.DecodeRune(l.src[l.offset:])
+		l.advance(size)
+		return 0, fmt.Errorf("%w: \\%c", ErrInvalidEscape, r)
+	}
+}
+
+// readUnicodeEscape decodes the `{XXXXXX}` part of a `\u{XXXXXX}`
+// escape, up to 6 hex digits representing a unicode scalar value.
+func (l *Lexer) readUnicodeEscape() (rune, error) {
+	if l.peekByte(0) != '{' {
+		return 0, fmt.Errorf("%w: expected { after \\u", ErrInvalidEscape)
+	}
+	l.advance(1)
+
+	start := l.offset
+	for isHexDigit(l.peekByte(0)) {
+		l.advance(1)
+	}
+	digits := l.text[start:l.offset]
+
+	if l.peekByte(0) != '}' {
+		return 0, fmt.Errorf("%w: unclosed \\u{", ErrInvalidEscape)
+	}
+	l.advance(1)
+
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, fmt.Errorf("%w: \\u{} expects 1 to 6 hex digits", ErrInvalidEscape)
+	}
+
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(value)) {
+		return 0, fmt.Errorf("%w: \\u{%s} is not a unicode scalar value", ErrInvalidEscape, digits)
+	}
+
+	return rune(value), nil
+}
+
+// readRawString reads a backtick delimited string, raw strings have
+// no escape sequences and can span multiple lines. Carriage returns
+// are discarded so the value does not depend on the file line endings.
+func (l *Lexer) readRawString(line, column int) Token {
+	tokStart := l.offset
+	l.advance(1) // opening backtick
+
+	start := l.offset
+	idx := bytes.IndexByte(l.src[start:], '`')
+	if idx < 0 {
+		l.advance(len(l.src) - l.offset)
+		l.report(line, column, ErrUnterminatedString)
+		return Token{Type: ILLEGAL, Literal: l.text[tokStart:l.offset]}
+	}
+
+	raw := l.text[start : start+idx]
+	l.advance(idx + 1)
+
+	if strings.IndexByte(raw, '\r') >= 0 {
+		raw = strings.ReplaceAll(raw, "\r", "")
+	}
+
+	return Token{Type: STRING, Literal: raw}
+}
+
+// NumericSuffixes are the type suffixes a numeric literal can have, e.g: 10i64, 2.5f32
+var NumericSuffixes = map[string]bool{
+	"i8": true, "i16": true, "i32": true, "i64": true, "isize": true,
+	"u8": true, "u16": true, "u32": true, "u64": true, "usize": true,
+	"f32": true, "f64": true,
+}
+
+// readNumber reads an integer or float literal. Integers accept the 0x, 0o
+// and 0b base prefixes, floats accept an exponent and both can have `_` digit
+// separators and a type suffix. The literal is kept as written and its value
+// is decoded by the parser.
+func (l *Lexer) readNumber(line, column int) Token {
+	start := l.offset
+	tokType := INT
+
+	base := 10
+	if l.src[l.offset] == '0' {
+		switch l.peekByte(1) {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+
+		if base != 10 {
+			l.advance(2)
+		}
+	}
+
+	if err := checkDigits(l.readDigits(base), base); err != nil {
+		return l.illegalNumber(line, column, start, err)
+	}
+
+	if base == 10 {
+		// only a digit after the dot makes a fraction, so `0..n` is a range
+		if l.peekByte(0) == '.' && isDigit(l.peekByte(1)) {
+			tokType = FLOAT
+			l.advance(1)
+			if err := checkDigits(l.readDigits(base), base); err != nil {
+				return l.illegalNumber(line, column, start, err)
+			}
+		}
+
+		if ch := l.peekByte(0); ch == 'e' || ch == 'E' {
+			tokType = FLOAT
+			l.advance(1)
+			if ch := l.peekByte(0); ch == '+' || ch == '-' {
+				l.advance(1)
 			}
+
+			if err := checkDigits(l.readDigits(base), base); err != nil {
+				return l.illegalNumber(line, column, start, fmt.Errorf("exponent %w", err))
+			}
+		}
+	}
+
+	if r, _ := utf8.DecodeRune(l.src[l.offset:]); l.offset < len(l.src) && isIdentStart(r) {
+		suffixStart := l.offset
+		l.skipIdent()
+		suffix := l.text[suffixStart:l.offset]
+
+		if !NumericSuffixes[suffix] {
+			return l.illegalNumber(line, column, start, fmt.Errorf("invalid suffix %s", suffix))
 		}
 
-		return Token{Type: FLOAT, Literal: buf.String()}
+		if suffix[0] == 'f' && base != 10 {
+			return l.illegalNumber(line, column, start, fmt.Errorf("float suffix %s on base %d literal", suffix, base))
+		}
+
+		if suffix[0] != 'f' && tokType == FLOAT {
+			return l.illegalNumber(line, column, start, fmt.Errorf("integer suffix %s on float literal", suffix))
+		}
 	}
 
-	return Token{Type: INT, Literal: buf.String()}
+	return Token{Type: tokType, Literal: l.text[start:l.offset]}
 }
 
-func (l *Lexer) readFloat() Token {
-	var buf bytes.Buffer
-	buf.WriteRune(l.read())
+// readDigits reads digits and `_` separators, for base 16 hex digits
+// are read, other bases read decimal digits that are validated later.
+func (l *Lexer) readDigits(base int) string {
+	start := l.offset
 	for {
-		if ch := l.read(); isDigit(ch) || ch == '.' {
-			buf.WriteRune(ch)
-		} else {
-			l.unread()
-			break
+		ch := l.peekByte(0)
+		if isDigit(ch) || ch == '_' || (base == 16 && isHexDigit(ch)) {
+			l.offset++
+			l.column++
+			continue
+		}
+
+		return l.text[start:l.offset]
+	}
+}
+
+// checkDigits validates that digits are valid for the base
+// and that `_` only appears between two digits.
+func checkDigits(digits string, base int) error {
+	if len(digits) == 0 {
+		return errors.New("has no digits")
+	}
+
+	if digits[0] == '_' || digits[len(digits)-1] == '_' || strings.Contains(digits, "__") {
+		return errors.New("`_` must separate successive digits")
+	}
+
+	for _, ch := range digits {
+		if ch != '_' && base < 10 && ch-'0' >= rune(base) {
+			return fmt.Errorf("invalid digit %q in base %d literal", ch, base)
 		}
 	}
-	return Token{Type: FLOAT, Literal: buf.String()}
+
+	return nil
 }
 
-func (l *Lexer) peek() rune {
-	ch := l.read()
-	l.unread()
-	return ch
+func (l *Lexer) illegalNumber(line, column, start int, err error) Token {
+	// consume the rest of the malformed literal so it is reported once
+	l.skipIdent()
+
+	literal := l.text[start:l.offset]
+	l.report(line, column, fmt.Errorf("%w %s: %w", ErrInvalidNumber, literal, err))
+	return Token{Type: ILLEGAL, Literal: literal}
+}
+
+const (
+	classSpace = 1 << iota
+	classDigit
+	classHexDigit
+	classIdentStart
+)
+
+// charClasses classifies ascii characters, non ascii
+// characters are classified by the unicode tables.
+var charClasses = func() (classes [256]uint8) {
+	for ch := '0'; ch <= '9'; ch++ {
+		classes[ch] |= classDigit | classHexDigit
+	}
+
+	for ch := 'a'; ch <= 'z'; ch++ {
+		classes[ch] |= classIdentStart
+		classes[ch-'a'+'A'] |= classIdentStart
+	}
+
+	for ch := 'a'; ch <= 'f'; ch++ {
+		classes[ch] |= classHexDigit
+		classes[ch-'a'+'A'] |= classHexDigit
+	}
+
+	classes['_'] |= classIdentStart
+	classes[' '] |= classSpace
+	classes['\t'] |= classSpace
+	classes['\r'] |= classSpace
+	return classes
+}()
+
+func isDigit(ch byte) bool {
+	return charClasses[ch]&classDigit != 0
 }
 
-func isLetter(ch rune) bool {
-	return strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", ch)
+func isHexDigit(ch byte) bool {
+	return charClasses[ch]&classHexDigit != 0
 }
 
-func isDigit(ch rune) bool {
-	return strings.ContainsRune("0123456789", ch)
+func isWhitespace(ch byte) bool {
+	return charClasses[ch]&classSpace != 0
 }
 
-func isWhitespace(ch rune) bool {
-	return strings.ContainsRune(" \t\r", ch)
+func isASCIIIdentStart(ch byte) bool {
+	return charClasses[ch]&classIdentStart != 0
 }
@@ -1,6 +1,7 @@
 package lexer_test
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -13,24 +14,24 @@ func TestLexerNextToken(t *testing.T) {
 	l := lexer.NewLexer(strings.NewReader(input))
 
 	exepectedTokens := []lexer.Token{
-		{Type: lexer.VAR, Literal: "var", Line: 1, Column: 0},
-		{Type: lexer.IDENT, Literal: "x", Line: 1, Column: 4},
-		{Type: lexer.ASSIGN, Literal: "=", Line: 1, Column: 6},
-		{Type: lexer.INT, Literal: "42", Line: 1, Column: 8},
-		{Type: lexer.PLUS, Literal: "+", Line: 1, Column: 11},
-		{Type: lexer.INT, Literal: "5", Line: 1, Column: 13},
-		{Type: lexer.MAIN, Literal: "main", Line: 1, Column: 15},
-		{Type: lexer.FN, Literal: "fn", Line: 1, Column: 20},
-		{Type: lexer.CONTINUE, Literal: "continue", Line: 1, Column: 23},
-		{Type: lexer.IF, Literal: "if", Line: 1, Column: 32},
-		{Type: lexer.BREAK, Literal: "break", Line: 1, Column: 35},
-		{Type: lexer.LBRACE, Literal: "{", Line: 1, Column: 41},
-		{Type: lexer.RBRACE, Literal: "}", Line: 1, Column: 43},
-		{Type: lexer.LPAREN, Literal: "(", Line: 1, Column: 45},
-		{Type: lexer.RPAREN, Literal: ")", Line: 1, Column: 47},
-		{Type: lexer.FLOAT, Literal: "3.14", Line: 1, Column: 49},
-		{Type: lexer.SEMICOLON, Literal: ";", Line: 1, Column: 54},
-		{Type: lexer.EOF, Literal: "", Line: 0, Column: 0},
+		{Type: lexer.VAR, Literal: "var", Line: 1, Column: 0, Start: 0, End: 3},
+		{Type: lexer.IDENT, Literal: "x", Line: 1, Column: 4, Start: 4, End: 5},
+		{Type: lexer.ASSIGN, Literal: "=", Line: 1, Column: 6, Start: 6, End: 7},
+		{Type: lexer.INT, Literal: "42", Line: 1, Column: 8, Start: 8, End: 10},
+		{Type: lexer.PLUS, Literal: "+", Line: 1, Column: 11, Start: 11, End: 12},
+		{Type: lexer.INT, Literal: "5", Line: 1, Column: 13, Start: 13, End: 14},
+		{Type: lexer.IDENT, Literal: "main", Line: 1, Column: 15, Start: 15, End: 19},
+		{Type: lexer.FN, Literal: "fn", Line: 1, Column: 20, Start: 20, End: 22},
+		{Type: lexer.CONTINUE, Literal: "continue", Line: 1, Column: 23, Start: 23, End: 31},
+		{Type: lexer.IF, Literal: "if", Line: 1, Column: 32, Start: 32, End: 34},
+		{Type: lexer.BREAK, Literal: "break", Line: 1, Column: 35, Start: 35, End: 40},
+		{Type: lexer.LBRACE, Literal: "{", Line: 1, Column: 41, Start: 41, End: 42},
+		{Type: lexer.RBRACE, Literal: "}", Line: 1, Column: 43, Start: 43, End: 44},
+		{Type: lexer.LPAREN, Literal: "(", Line: 1, Column: 45, Start: 45, End: 46},
+		{Type: lexer.RPAREN, Literal: ")", Line: 1, Column: 47, Start: 47, End: 48},
+		{Type: lexer.FLOAT, Literal: "3.14", Line: 1, Column: 49, Start: 49, End: 53},
+		{Type: lexer.SEMICOLON, Literal: ";", Line: 1, Column: 54, Start: 54, End: 55},
+		{Type: lexer.EOF, Literal: "", Line: 1, Column: 55, Start: 55, End: 55},
 	}
 
 	var tokens []lexer.Token
@@ -40,3 +41,481 @@ func TestLexerNextToken(t *testing.T) {
 
 	require.Equal(t, exepectedTokens, tokens)
 }
+
+func TestLexerComments(t *testing.T) {
+	input := `// line comment
+/// adds one
+/// to x
+var x = 1 /* block /* nested */ comment */ + 2
+//// not a doc
+/// detached
+
+var y = 3`
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	exepectedTokens := []lexer.Token{
+		{Type: lexer.VAR, Literal: "var", Line: 4, Column: 0, Start: 38, End: 41, Doc: "adds one\nto x"},
+		{Type: lexer.IDENT, Literal: "x", Line: 4, Column: 4, Start: 42, End: 43},
+		{Type: lexer.ASSIGN, Literal: "=", Line: 4, Column: 6, Start: 44, End: 45},
+		{Type: lexer.INT, Literal: "1", Line: 4, Column: 8, Start: 46, End: 47},
+		{Type: lexer.PLUS, Literal: "+", Line: 4, Column: 43, Start: 81, End: 82},
+		{Type: lexer.INT, Literal: "2", Line: 4, Column: 45, Start: 83, End: 84},
+		{Type: lexer.SEMICOLON, Literal: "\n", Line: 4, Column: 46, Start: 84, End: 85},
+		{Type: lexer.VAR, Literal: "var", Line: 8, Column: 0, Start: 114, End: 117},
+		{Type: lexer.IDENT, Literal: "y", Line: 8, Column: 4, Start: 118, End: 119},
+		{Type: lexer.ASSIGN, Literal: "=", Line: 8, Column: 6, Start: 120, End: 121},
+		{Type: lexer.INT, Literal: "3", Line: 8, Column: 8, Start: 122, End: 123},
+		{Type: lexer.SEMICOLON, Literal: "", Line: 8, Column: 9, Start: 123, End: 123},
+		{Type: lexer.EOF, Literal: "", Line: 8, Column: 9, Start: 123, End: 123},
+	}
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, exepectedTokens, tokens)
+}
+
+func TestLexerUnterminatedBlockComment(t *testing.T) {
+	l := lexer.NewLexer(strings.NewReader("var x /* never closed"))
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, lexer.Token{Type: lexer.ILLEGAL, Literal: "/* never closed", Line: 1, Column: 6, Start: 6, End: 21}, tokens[2])
+
+	errs := l.Diagnostics().Errors()
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], lexer.ErrUnterminatedComment)
+}
+
+func TestLexerSemicolonInsertion(t *testing.T) {
+	input := `var x = a +
+	b
+return
+foo(1,
+	2) /* spans
+lines */ }
+{
+`
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	expected := []lexer.TokenType{
+		lexer.VAR, lexer.IDENT, lexer.ASSIGN, lexer.IDENT, lexer.PLUS,
+		lexer.IDENT, lexer.SEMICOLON,
+		lexer.RETURN, lexer.SEMICOLON,
+		lexer.IDENT, lexer.LPAREN, lexer.INT, lexer.COMMA,
+		lexer.INT, lexer.RPAREN, lexer.SEMICOLON,
+		lexer.RBRACE, lexer.SEMICOLON,
+		lexer.LBRACE,
+		lexer.EOF,
+	}
+
+	var tokens []lexer.TokenType
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok.Type)
+	}
+
+	require.Equal(t, expected, tokens)
+}
+
+func TestLexerCompoundAssignments(t *testing.T) {
+	input := `x += 1 -= 2 *= 3 /= 4 %= 5
+i++
+j--
+k + +1 - -1`
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	expected := []lexer.TokenType{
+		lexer.IDENT, lexer.PLUS_ASSIGN, lexer.INT, lexer.MINUS_ASSIGN, lexer.INT,
+		lexer.STAR_ASSIGN, lexer.INT, lexer.SLASH_ASSIGN, lexer.INT,
+		lexer.PERCENT_ASSIGN, lexer.INT, lexer.SEMICOLON,
+		lexer.IDENT, lexer.INC, lexer.SEMICOLON,
+		lexer.IDENT, lexer.DEC, lexer.SEMICOLON,
+		lexer.IDENT, lexer.PLUS, lexer.PLUS, lexer.INT,
+		lexer.MINUS, lexer.MINUS, lexer.INT, lexer.SEMICOLON,
+		lexer.EOF,
+	}
+
+	var tokens []lexer.TokenType
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok.Type)
+	}
+
+	require.Equal(t, expected, tokens)
+}
+
+func TestLexerStrings(t *testing.T) {
+	input := "\"a\\tb\\n\\\"c\\\"\\\\ \\u{1F600}\" \"\" `raw \\n\nline`"
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	exepectedTokens := []lexer.Token{
+		{Type: lexer.STRING, Literal: "a\tb\n\"c\"\\ 😀", Line: 1, Column: 0, Start: 0, End: 25},
+		{Type: lexer.STRING, Literal: "", Line: 1, Column: 26, Start: 26, End: 28},
+		{Type: lexer.STRING, Literal: "raw \\n\nline", Line: 1, Column: 29, Start: 29, End: 42},
+		{Type: lexer.SEMICOLON, Literal: "", Line: 2, Column: 5, Start: 42, End: 42},
+		{Type: lexer.EOF, Literal: "", Line: 2, Column: 5, Start: 42, End: 42},
+	}
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, exepectedTokens, tokens)
+	require.Empty(t, l.Diagnostics().Errors())
+}
+
+func TestLexerStringErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected []*lexer.ErrLexer
+	}{
+		"unterminated_at_eof": {
+			input:    `var x = "abc`,
+			expected: []*lexer.ErrLexer{{Line: 1, Column: 8, Err: lexer.ErrUnterminatedString}},
+		},
+		"unterminated_at_new_line": {
+			input:    "var x = \"abc\nvar y = 1",
+			expected: []*lexer.ErrLexer{{Line: 1, Column: 8, Err: lexer.ErrUnterminatedString}},
+		},
+		"unterminated_raw_string": {
+			input:    "var x = `abc\n",
+			expected: []*lexer.ErrLexer{{Line: 1, Column: 8, Err: lexer.ErrUnterminatedString}},
+		},
+		"invalid_escape": {
+			input:    `var x = "a\qb"`,
+			expected: []*lexer.ErrLexer{{Line: 1, Column: 10, Err: lexer.ErrInvalidEscape}},
+		},
+		"invalid_unicode_escape": {
+			input:    `var x = "\u{D800}"`,
+			expected: []*lexer.ErrLexer{{Line: 1, Column: 9, Err: lexer.ErrInvalidEscape}},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+
+			var illegal *lexer.Token
+			for tok := range l.NextToken() {
+				if tok.Type == lexer.ILLEGAL {
+					illegal = &tok
+				}
+			}
+
+			require.NotNil(t, illegal)
+
+			errs := l.Diagnostics().Errors()
+			require.Len(t, errs, len(tt.expected))
+			for idx, expected := range tt.expected {
+				require.Equal(t, expected.Line, errs[idx].Line)
+				require.Equal(t, expected.Column, errs[idx].Column)
+				require.ErrorIs(t, errs[idx].Err, expected.Err)
+			}
+		})
+	}
+}
+
+func TestLexerOperators(t *testing.T) {
+	input := "== != < <= > >= && || ! % & | ^ << >> = .. ..= ~ [ ] ."
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	exepectedTokens := []lexer.Token{
+		{Type: lexer.EQ, Literal: "==", Line: 1, Column: 0, Start: 0, End: 2},
+		{Type: lexer.NOT_EQ, Literal: "!=", Line: 1, Column: 3, Start: 3, End: 5},
+		{Type: lexer.LT, Literal: "<", Line: 1, Column: 6, Start: 6, End: 7},
+		{Type: lexer.LT_EQ, Literal: "<=", Line: 1, Column: 8, Start: 8, End: 10},
+		{Type: lexer.GT, Literal: ">", Line: 1, Column: 11, Start: 11, End: 12},
+		{Type: lexer.GT_EQ, Literal: ">=", Line: 1, Column: 13, Start: 13, End: 15},
+		{Type: lexer.AND, Literal: "&&", Line: 1, Column: 16, Start: 16, End: 18},
+		{Type: lexer.OR, Literal: "||", Line: 1, Column: 19, Start: 19, End: 21},
+		{Type: lexer.BANG, Literal: "!", Line: 1, Column: 22, Start: 22, End: 23},
+		{Type: lexer.PERCENT, Literal: "%", Line: 1, Column: 24, Start: 24, End: 25},
+		{Type: lexer.AMPERSAND, Literal: "&", Line: 1, Column: 26, Start: 26, End: 27},
+		{Type: lexer.PIPE, Literal: "|", Line: 1, Column: 28, Start: 28, End: 29},
+		{Type: lexer.CARET, Literal: "^", Line: 1, Column: 30, Start: 30, End: 31},
+		{Type: lexer.SHL, Literal: "<<", Line: 1, Column: 32, Start: 32, End: 34},
+		{Type: lexer.SHR, Literal: ">>", Line: 1, Column: 35, Start: 35, End: 37},
+		{Type: lexer.ASSIGN, Literal: "=", Line: 1, Column: 38, Start: 38, End: 39},
+		{Type: lexer.DOTDOT, Literal: "..", Line: 1, Column: 40, Start: 40, End: 42},
+		{Type: lexer.DOTDOT_EQ, Literal: "..=", Line: 1, Column: 43, Start: 43, End: 46},
+		{Type: lexer.TILDE, Literal: "~", Line: 1, Column: 47, Start: 47, End: 48},
+		{Type: lexer.LBRACKET, Literal: "[", Line: 1, Column: 49, Start: 49, End: 50},
+		{Type: lexer.RBRACKET, Literal: "]", Line: 1, Column: 51, Start: 51, End: 52},
+		{Type: lexer.DOT, Literal: ".", Line: 1, Column: 53, Start: 53, End: 54},
+		{Type: lexer.EOF, Literal: "", Line: 1, Column: 54, Start: 54, End: 54},
+	}
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, exepectedTokens, tokens)
+}
+
+func TestLexerIllegalCharacters(t *testing.T) {
+	input := "var x @= 1 $\n# y"
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	exepectedTokens := []lexer.Token{
+		{Type: lexer.VAR, Literal: "var", Line: 1, Column: 0, Start: 0, End: 3},
+		{Type: lexer.IDENT, Literal: "x", Line: 1, Column: 4, Start: 4, End: 5},
+		{Type: lexer.ASSIGN, Literal: "=", Line: 1, Column: 7, Start: 7, End: 8},
+		{Type: lexer.INT, Literal: "1", Line: 1, Column: 9, Start: 9, End: 10},
+		{Type: lexer.SEMICOLON, Literal: "\n", Line: 1, Column: 12, Start: 12, End: 13},
+		{Type: lexer.IDENT, Literal: "y", Line: 2, Column: 2, Start: 15, End: 16},
+		{Type: lexer.SEMICOLON, Literal: "", Line: 2, Column: 3, Start: 16, End: 16},
+		{Type: lexer.EOF, Literal: "", Line: 2, Column: 3, Start: 16, End: 16},
+	}
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, exepectedTokens, tokens)
+
+	errs := l.Diagnostics().Errors()
+	require.Len(t, errs, 3)
+
+	positions := [][2]int{{1, 6}, {1, 11}, {2, 0}}
+	for idx, err := range errs {
+		require.ErrorIs(t, err, lexer.ErrIllegalCharacter)
+		require.Equal(t, positions[idx], [2]int{err.Line, err.Column})
+	}
+}
+
+func TestLexerNumbers(t *testing.T) {
+	input := "0xFF_FF 0o17 0b1010 1_000_000 3.14 1e-9 2.5E+3 10i64 2.5f32 0xffu8 0..n 017"
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	expected := []struct {
+		tokType lexer.TokenType
+		literal string
+	}{
+		{lexer.INT, "0xFF_FF"},
+		{lexer.INT, "0o17"},
+		{lexer.INT, "0b1010"},
+		{lexer.INT, "1_000_000"},
+		{lexer.FLOAT, "3.14"},
+		{lexer.FLOAT, "1e-9"},
+		{lexer.FLOAT, "2.5E+3"},
+		{lexer.INT, "10i64"},
+		{lexer.FLOAT, "2.5f32"},
+		{lexer.INT, "0xffu8"},
+		{lexer.INT, "0"},
+		{lexer.DOTDOT, ".."},
+		{lexer.IDENT, "n"},
+		{lexer.INT, "017"},
+		{lexer.SEMICOLON, ""},
+		{lexer.EOF, ""},
+	}
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Empty(t, l.Diagnostics().Errors())
+	require.Len(t, tokens, len(expected))
+	for idx, tok := range tokens {
+		require.Equal(t, expected[idx].tokType, tok.Type, tok.Literal)
+		require.Equal(t, expected[idx].literal, tok.Literal)
+	}
+}
+
+func TestLexerInvalidNumbers(t *testing.T) {
+	inputs := []string{"0x", "0b102", "1__0", "1_", "1e", "1e+", "10i7", "0b1f32", "5abc", "2.5i32", "1e3u8"}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+
+			var tokens []lexer.Token
+			for tok := range l.NextToken() {
+				tokens = append(tokens, tok)
+			}
+
+			require.Len(t, tokens, 2)
+			require.Equal(t, lexer.ILLEGAL, tokens[0].Type)
+			require.Equal(t, input, tokens[0].Literal)
+
+			errs := l.Diagnostics().Errors()
+			require.Len(t, errs, 1)
+			require.ErrorIs(t, errs[0], lexer.ErrInvalidNumber)
+		})
+	}
+}
+
+func TestLexerIdentifiers(t *testing.T) {
+	input := "my_var _tmp x1 número π_radius 変数 cafe\u0301"
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	var literals []string
+	for tok := range l.NextToken() {
+		if tok.Type == lexer.IDENT {
+			literals = append(literals, tok.Literal)
+		}
+	}
+
+	require.Empty(t, l.Diagnostics().Errors())
+	// the combining accent is normalized to the precomposed é
+	require.Equal(t, []string{"my_var", "_tmp", "x1", "número", "π_radius", "変数", "café"}, literals)
+}
+
+func TestLexerSuspiciousIdentifiers(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected error
+	}{
+		"zero_width_space": {
+			input:    "var co\u200Bunt = 1",
+			expected: lexer.ErrInvisibleCharacter,
+		},
+		"zero_width_joiner": {
+			input:    "var a\u200Db = 1",
+			expected: lexer.ErrInvisibleCharacter,
+		},
+		"zero_width_non_joiner": {
+			input:    "var a\u200Cb = 1",
+			expected: lexer.ErrInvisibleCharacter,
+		},
+		"bidi_override": {
+			input:    "var x = \u202E1",
+			expected: lexer.ErrInvisibleCharacter,
+		},
+		"cyrillic_a_in_latin": {
+			input:    "var pаssword = 1",
+			expected: lexer.ErrConfusableIdentifier,
+		},
+		"greek_omicron_in_latin": {
+			input:    "var fοο = 1",
+			expected: lexer.ErrConfusableIdentifier,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			for range l.NextToken() {
+			}
+
+			errs := l.Diagnostics().Errors()
+			require.Len(t, errs, 1)
+			require.ErrorIs(t, errs[0], tt.expected)
+		})
+	}
+}
+
+func TestLexerChars(t *testing.T) {
+	input := `'a' '\n' '\'' '\u{1F600}' 'é' char`
+	l := lexer.NewLexer(strings.NewReader(input))
+
+	var tokens []lexer.Token
+	for tok := range l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	require.Empty(t, l.Diagnostics().Errors())
+	require.Len(t, tokens, 8)
+
+	expected := []string{"a", "\n", "'", "😀", "é"}
+	for idx, literal := range expected {
+		require.Equal(t, lexer.CHAR, tokens[idx].Type)
+		require.Equal(t, literal, tokens[idx].Literal)
+	}
+	require.Equal(t, lexer.RAWTYPE, tokens[5].Type)
+}
+
+func TestLexerInvalidChars(t *testing.T) {
+	inputs := map[string]error{
+		"''":     lexer.ErrInvalidChar,
+		"'ab'":   lexer.ErrInvalidChar,
+		"'a":     lexer.ErrInvalidChar,
+		`'\q'`:   lexer.ErrInvalidEscape,
+		"'\n'":   lexer.ErrInvalidChar,
+		`'\u{}'`: lexer.ErrInvalidEscape,
+	}
+
+	for input, expected := range inputs {
+		t.Run(input, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+			for range l.NextToken() {
+			}
+
+			errs := l.Diagnostics().Errors()
+			require.NotEmpty(t, errs)
+			require.ErrorIs(t, errs[0], expected)
+		})
+	}
+}
+
+const benchmarkSource = `/// adds two numbers
+fn add(a: int32, b: int32): int32 {
+	// the sum of both arguments
+	var total = a + b * 0xff_ff - 1_000 % 7;
+	var name = "lotus";
+	var escaped = "tab\tnew line\n";
+	var ok = total >= 10 && total != 42 || !(a < b);
+	return total;
+}
+`
+
+func generateSource(size int) []byte {
+	var src bytes.Buffer
+	for src.Len() < size {
+		src.WriteString(benchmarkSource)
+	}
+	return src.Bytes()
+}
+
+func TestLexerAllocationsDoNotGrowWithInput(t *testing.T) {
+	tokenize := func(src []byte) func() {
+		return func() {
+			l := lexer.NewLexerFromBytes(src)
+			for range l.NextToken() {
+			}
+		}
+	}
+
+	small := testing.AllocsPerRun(10, tokenize(generateSource(1<<10)))
+	large := testing.AllocsPerRun(10, tokenize(generateSource(1<<20)))
+
+	// only strings with escapes and doc comments are allowed to allocate
+	perBlock := float64(4)
+	blocks := float64(len(generateSource(1<<20))/len(benchmarkSource)) - float64(len(generateSource(1<<10))/len(benchmarkSource))
+	require.LessOrEqual(t, large-small, perBlock*blocks)
+}
+
+func BenchmarkLexer(b *testing.B) {
+	src := generateSource(4 << 20)
+
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.NewLexerFromBytes(src)
+		for range l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkLexerReader(b *testing.B) {
+	src := generateSource(4 << 20)
+
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.NewLexer(bytes.NewReader(src))
+		for range l.NextToken() {
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package lexer
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// isIdentStart reports if ch can start an identifier, following
+// the unicode XID_Start property with `_` also allowed.
+func isIdentStart(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return isASCIIIdentStart(byte(ch))
+	}
+
+	if unicode.Is(unicode.Pattern_Syntax, ch) || unicode.Is(unicode.Pattern_White_Space, ch) {
+		return false
+	}
+
+	return unicode.In(ch, unicode.L, unicode.Nl, unicode.Other_ID_Start)
+}
+
+// isIdentContinue reports if ch can continue an identifier,
+// following the unicode XID_Continue property.
+func isIdentContinue(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return isASCIIIdentStart(byte(ch)) || isDigit(byte(ch))
+	}
+
+	return isIdentStart(ch) ||
+		unicode.In(ch, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue)
+}
+
+// isInvisible reports if ch is a zero width or a bidirectional
+// control character, those change how the code is displayed
+// without being visible themselves.
+func isInvisible(ch rune) bool {
+	switch {
+	case ch == '\u00AD', ch == '\u034F', ch == '\uFEFF':
+		return true
+	case '\u200B' <= ch && ch <= '\u200F':
+		return true
+	case '\u202A' <= ch && ch <= '\u202E':
+		return true
+	case '\u2060' <= ch && ch <= '\u2069':
+		return true
+	}
+
+	return false
+}
+
+// confusables maps greek and cyrillic letters to the latin
+// letter they cannot be visually distinguished from.
+var confusables = map[rune]rune{
+	// cyrillic
+	'а': 'a', 'в': 'B', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+	'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'ӏ': 'l', 'һ': 'h', 'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M',
+	'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'У': 'Y',
+	'Ѕ': 'S', 'І': 'I', 'Ј': 'J',
+	// greek
+	'ο': 'o', 'ν': 'v', 'ρ': 'p', 'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z',
+	'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P',
+	'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// normalizeIdent returns the NFC form of an identifier, so identifiers
+// written with precomposed or combining characters are the same.
+func normalizeIdent(ident string) string {
+	for idx := 0; idx < len(ident); idx++ {
+		if ident[idx] >= utf8.RuneSelf {
+			return norm.NFC.String(ident)
+		}
+	}
+
+	return ident
+}
+
+// checkConfusable returns an error if the identifier mixes latin
+// letters with a letter that looks exactly like a latin one.
+func checkConfusable(ident string) error {
+	hasLatin := false
+	confusable := rune(-1)
+	for _, ch := range ident {
+		if ch < utf8.RuneSelf || unicode.Is(unicode.Latin, ch) {
+			hasLatin = hasLatin || unicode.IsLetter(ch)
+			continue
+		}
+
+		if _, ok := confusables[ch]; ok && confusable == -1 {
+			confusable = ch
+		}
+	}
+
+	if hasLatin && confusable != -1 {
+		return fmt.Errorf("%w: %s contains %q (%U) that looks like latin %q",
+			ErrConfusableIdentifier, ident, confusable, confusable, confusables[confusable])
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -13,13 +15,16 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: main <source file>")
+	noBoundsChecks := flag.Bool("no-bounds-checks", false, "do not check array and slice indexes at runtime")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: main [-no-bounds-checks] <source file>")
 		return
 	}
 
 	sources := strings.Builder{}
-	for _, sourceFile := range os.Args[1:] {
+	for _, sourceFile := range flag.Args() {
 		fmt.Printf("reading %s ...\n", sourceFile)
 		source, err := os.ReadFile(sourceFile)
 		if err != nil {
@@ -38,12 +43,25 @@ func main() {
 	p := parser.NewParser(tokens)
 
 	program, err := p.ParseProgram()
-	if err != nil {
-		fmt.Printf("Error parsing program: %v\n", err)
+	if lexErrs := l.Diagnostics().Errors(); len(lexErrs) > 0 {
+		for _, lexErr := range lexErrs {
+			fmt.Printf("Error scanning program: %v\n", lexErr)
+		}
+		return
+	}
+
+	var parseErrs parser.ErrList
+	if errors.As(err, &parseErrs) {
+		for _, parseErr := range parseErrs {
+			fmt.Printf("Error parsing program: %v\n", parseErr)
+		}
 		return
 	}
 
 	irGen := llvm.NewIRGenerator()
+	if *noBoundsChecks {
+		irGen.DisableBoundsChecks()
+	}
 	irGen.GenerateIR(program)
 
 	err = gollvm.VerifyModule(irGen.Module, gollvm.PrintMessageAction)
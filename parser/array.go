@@ -0,0 +1,540 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/EclesioMeloJunior/lotus/lexer"
+)
+
+// ArrayType describes the array type [Len]Elem, or the slice type
+// []Elem when Slice is set. Arrays are values holding Len elements,
+// slices point to a run of elements of an array and know its length.
+type ArrayType struct {
+	Elem  Type
+	Len   int64
+	Slice bool
+}
+
+// ArrayLiteral builds an array value from its elements, e.g: [1, 2, 3]
+type ArrayLiteral struct {
+	Type     Type
+	Elements []Expression
+}
+
+func (*ArrayLiteral) expressionNode() {}
+
+// IndexExpression reads the element at Index of the array or slice
+// Value, e.g: a[i]. Line and Column locate the access in the source
+// for the out of range message.
+type IndexExpression struct {
+	Value  Expression
+	Index  Expression
+	Type   Type
+	Line   int
+	Column int
+}
+
+func (*IndexExpression) expressionNode() {}
+
+// SliceExpression takes the elements from Low up to, not including,
+// High of the array or slice Value, e.g: a[1..3]. Low is nil when it
+// starts at the first element and High when it ends at the last one.
+type SliceExpression struct {
+	Value  Expression
+	Low    Expression
+	High   Expression
+	Type   Type
+	Line   int
+	Column int
+}
+
+func (*SliceExpression) expressionNode() {}
+
+// LenExpression is the builtin len(Value), the number of elements
+// of an array or a slice, or the number of bytes of a string.
+type LenExpression struct {
+	Value Expression
+}
+
+func (*LenExpression) expressionNode() {}
+
+// AssignIndexStatement assigns Value to an element of an array
+// variable or of a slice, e.g: a[i] = 3
+type AssignIndexStatement struct {
+	Target *IndexExpression
+	Value  Expression
+}
+
+// firstArrayType is the Type of the first array or slice type in use,
+// every other array or slice type takes the next Type after it.
+const firstArrayType Type = 1 << 20
+
+// arrayType returns the Type of the array or slice type t, taking the
+// next one on first use. The same array type always gets the same
+// Type, so types are compared as integers.
+func (types *Types) arrayType(t ArrayType) Type {
+	if id, ok := types.arrayIDs[t]; ok {
+		return id
+	}
+
+	if types.arrayIDs == nil {
+		types.arrayIDs = make(map[ArrayType]Type)
+	}
+
+	id := firstArrayType + Type(len(types.arrays))
+	types.arrays = append(types.arrays, t)
+	types.arrayIDs[t] = id
+	return id
+}
+
+// Array returns the description of the array or slice type t,
+// or nil if t is neither an array nor a slice type.
+func (types *Types) Array(t Type) *ArrayType {
+	if !t.isArrayOrSlice() {
+		return nil
+	}
+
+	idx := int(t - firstArrayType)
+	if idx >= len(types.arrays) {
+		return nil
+	}
+
+	array := types.arrays[idx]
+	return &array
+}
+
+// isArrayOrSlice reports whether t is an array or a slice type.
+func (t Type) isArrayOrSlice() bool {
+	return t >= firstArrayType
+}
+
+// parseArrayType parses the array type [N]T or the slice type []T,
+// the current token is the opening bracket. The length is an integer
+// literal or an integer constant.
+func (p *Parser) parseArrayType() (Type, error) {
+	if p.peekTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+
+		elem, err := p.parseType()
+		if err != nil {
+			return Void, err
+		}
+		return p.types.arrayType(ArrayType{Elem: elem, Slice: true}), nil
+	}
+
+	p.nextToken()
+	lengthToken := p.curToken
+
+	var length Expression
+	switch p.curToken.Type {
+	case lexer.INT:
+		var err error
+		if length, err = p.parseNumericLiteral(Int64, false); err != nil {
+			return Void, &ErrParser{
+				Line:   lengthToken.Line,
+				Column: lengthToken.Column,
+				Err:    err,
+			}
+		}
+	case lexer.IDENT:
+		if stmt, ok := p.scope.lookup(p.curToken.Literal); ok && stmt.Const {
+			length = stmt.Value
+		}
+	}
+
+	literal, ok := length.(*IntegerLiteral)
+	if !ok || literal.Value < 0 {
+		return Void, &ErrParser{
+			Line:   lengthToken.Line,
+			Column: lengthToken.Column,
+			Err:    fmt.Errorf("array length must be a non negative integer constant, got %s", lengthToken.Literal),
+		}
+	}
+
+	// lengths are int32, like the result of len
+	if literal.Value > math.MaxInt32 {
+		return Void, &ErrParser{
+			Line:   lengthToken.Line,
+			Column: lengthToken.Column,
+			Err:    fmt.Errorf("array length %d overflows int32", literal.Value),
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.RBRACKET); err != nil {
+		return Void, err
+	}
+
+	elem, err := p.parseType()
+	if err != nil {
+		return Void, err
+	}
+	return p.types.arrayType(ArrayType{Elem: elem, Len: literal.Value}), nil
+}
+
+// parseArrayLiteral parses the elements of an array literal, they take
+// the element type of tt when it is an array type, otherwise the type
+// of the first element, e.g: [1, 2, 3] is a [3]int32.
+func (p *Parser) parseArrayLiteral(tt Type) (Expression, error) {
+	literal := &ArrayLiteral{}
+	line, column := p.curToken.Line, p.curToken.Column
+
+	elemType := Void
+	expected := p.types.Array(tt)
+	if expected != nil && !expected.Slice {
+		elemType = expected.Elem
+	}
+
+	for {
+		p.nextToken()
+		if p.curToken.Type == lexer.SEMICOLON {
+			continue
+		}
+
+		if p.curToken.Type == lexer.RBRACKET {
+			break
+		}
+
+		elem, err := p.parseExpression(LOWEST, elemType)
+		if err != nil {
+			return nil, err
+		}
+
+		if elemType == Void {
+			if elemType, err = p.inferTypeFromExpression(elem); err != nil {
+				return nil, &ErrParser{
+					Line:   p.curToken.Line,
+					Column: p.curToken.Column,
+					Err:    fmt.Errorf("array element: %w", err),
+				}
+			}
+
+			if elem, err = convertExpression(elem, elemType); err != nil {
+				return nil, &ErrParser{
+					Line:   p.curToken.Line,
+					Column: p.curToken.Column,
+					Err:    err,
+				}
+			}
+		}
+		literal.Elements = append(literal.Elements, elem)
+
+		switch {
+		case p.peekTokenIs(lexer.COMMA) || p.peekTokenIs(lexer.SEMICOLON):
+			p.nextToken()
+		case !p.peekTokenIs(lexer.RBRACKET):
+			return nil, &ErrParser{
+				Line:   p.peekToken.Line,
+				Column: p.peekToken.Column,
+				Err:    fmt.Errorf("expected: , or ], got: %s", p.peekToken.Type.String()),
+			}
+		}
+	}
+
+	if elemType == Void {
+		return nil, &ErrParser{
+			Line:   line,
+			Column: column,
+			Err:    errors.New("cannot infer the type of an empty array literal"),
+		}
+	}
+
+	array := ArrayType{Elem: elemType, Len: int64(len(literal.Elements))}
+	if expected != nil && !expected.Slice && expected.Len != array.Len {
+		return nil, &ErrParser{
+			Line:   line,
+			Column: column,
+			Err:    fmt.Errorf("%s literal needs %d elements, got %d", p.types.Name(tt), expected.Len, array.Len),
+		}
+	}
+
+	literal.Type = p.types.arrayType(array)
+	return literal, nil
+}
+
+// parseIndexExpression parses the index following an opening bracket,
+// e.g: a[i], or the bounds of a sub-slice, e.g: a[i..j], a[i..], a[..j].
+// Constant indexes of arrays are checked while parsing, the others
+// are checked at runtime.
+func (p *Parser) parseIndexExpression(value Expression) (Expression, error) {
+	line, column := p.curToken.Line, p.curToken.Column
+
+	valueType := TypeOf(value)
+	array := p.types.Array(valueType)
+	if array == nil {
+		return nil, &ErrParser{
+			Line:   line,
+			Column: column,
+			Err:    fmt.Errorf("type %s cannot be indexed", p.types.Name(valueType)),
+		}
+	}
+
+	var low Expression
+	if !p.peekTokenIs(lexer.DOTDOT) {
+		p.nextToken()
+
+		var err error
+		if low, err = p.parseIndex(); err != nil {
+			return nil, err
+		}
+
+		if p.peekTokenIs(lexer.RBRACKET) {
+			p.nextToken()
+
+			if err := p.checkConstantIndex(low, valueType, array.Len-1); err != nil {
+				return nil, &ErrParser{Line: line, Column: column, Err: err}
+			}
+
+			return &IndexExpression{
+				Value:  value,
+				Index:  low,
+				Type:   array.Elem,
+				Line:   line,
+				Column: column,
+			}, nil
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.DOTDOT); err != nil {
+		return nil, err
+	}
+
+	// a slice points to the elements of the array, so the array must
+	// be stored somewhere that outlives the expression
+	if !array.Slice && !p.addressable(value) {
+		return nil, &ErrParser{
+			Line:   line,
+			Column: column,
+			Err:    errors.New("cannot slice a temporary array, store it in a variable first"),
+		}
+	}
+
+	slice := &SliceExpression{
+		Value:  value,
+		Low:    low,
+		Type:   p.types.arrayType(ArrayType{Elem: array.Elem, Slice: true}),
+		Line:   line,
+		Column: column,
+	}
+
+	if !p.peekTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+
+		var err error
+		if slice.High, err = p.parseIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.RBRACKET); err != nil {
+		return nil, err
+	}
+
+	// slice bounds may be the length of the array
+	for _, bound := range []Expression{slice.Low, slice.High} {
+		if err := p.checkConstantIndex(bound, valueType, array.Len); err != nil {
+			return nil, &ErrParser{Line: line, Column: column, Err: err}
+		}
+	}
+
+	lowLiteral, lowConst := slice.Low.(*IntegerLiteral)
+	highLiteral, highConst := slice.High.(*IntegerLiteral)
+	if lowConst && highConst && lowLiteral.Value > highLiteral.Value {
+		return nil, &ErrParser{
+			Line:   line,
+			Column: column,
+			Err:    fmt.Errorf("invalid slice bounds %d..%d", lowLiteral.Value, highLiteral.Value),
+		}
+	}
+
+	return slice, nil
+}
+
+// parseIndex parses an index or a slice bound, any integer type is
+// accepted. Constant indexes are folded so their range is checked
+// while parsing.
+func (p *Parser) parseIndex() (Expression, error) {
+	index, err := p.parseExpression(LOWEST, Void)
+	if err != nil {
+		return nil, err
+	}
+
+	indexType, err := p.inferTypeFromExpression(index)
+	if err == nil && !indexType.IsInteger() {
+		err = fmt.Errorf("index must be an integer, got %s", indexType)
+	}
+
+	if err == nil {
+		index, err = convertExpression(index, indexType)
+	}
+
+	if err != nil {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    err,
+		}
+	}
+
+	if folded, err := foldConstant(index); err == nil {
+		return folded, nil
+	}
+	return index, nil
+}
+
+// checkConstantIndex checks the range of a constant index, it must not
+// be negative and, for arrays, not greater than limit. Indexes only
+// known at runtime are checked by the generated code.
+func (p *Parser) checkConstantIndex(index Expression, arrayType Type, limit int64) error {
+	literal, ok := index.(*IntegerLiteral)
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case literal.Value < 0 && !isUnsigned(literal.Type):
+		return fmt.Errorf("invalid index %d, index must not be negative", literal.Value)
+	case !p.types.Array(arrayType).Slice && (literal.Value < 0 || literal.Value > limit):
+		return fmt.Errorf("index %s out of range for %s", formatNumber(literal), p.types.Name(arrayType))
+	}
+	return nil
+}
+
+// addressable reports whether the value of expr is stored in memory:
+// a variable, a field or an element of an addressable value, or an
+// element of a slice.
+func (p *Parser) addressable(expr Expression) bool {
+	switch expr := expr.(type) {
+	case *Identifier:
+		return true
+	case *FieldAccess:
+		return p.addressable(expr.Value)
+	case *IndexExpression:
+		return p.types.Array(TypeOf(expr.Value)).Slice || p.addressable(expr.Value)
+	default:
+		return false
+	}
+}
+
+// checkSliceEscape fails if value holds a slice of an array stored in
+// a local variable or a parameter, e.g: a[1..], as the slice would
+// point to the stack frame of a function that already returned.
+func (p *Parser) checkSliceEscape(value Expression) error {
+	if name, ok := p.sliceOrigin(value); ok {
+		return fmt.Errorf("slice of the local array %s cannot outlive its function", name)
+	}
+	return nil
+}
+
+// sliceOrigin returns the local array value may point into: a slice
+// of it, a literal holding one or a variable assigned one before.
+func (p *Parser) sliceOrigin(value Expression) (string, bool) {
+	switch value := value.(type) {
+	case *SliceExpression:
+		if p.types.Array(TypeOf(value.Value)).Slice {
+			return p.sliceOrigin(value.Value)
+		}
+		return p.localArray(value)
+	case *Identifier:
+		if stmt, ok := p.scope.lookup(value.Value); ok {
+			name, ok := p.slices[stmt]
+			return name, ok
+		}
+	case *FieldAccess:
+		if value.Type.IsStruct() || value.Type.isArrayOrSlice() {
+			return p.sliceOrigin(value.Value)
+		}
+	case *IndexExpression:
+		if value.Type.IsStruct() || value.Type.isArrayOrSlice() {
+			return p.sliceOrigin(value.Value)
+		}
+	case *StructLiteral:
+		for _, field := range value.Fields {
+			if name, ok := p.sliceOrigin(field); ok {
+				return name, true
+			}
+		}
+	case *ArrayLiteral:
+		for _, elem := range value.Elements {
+			if name, ok := p.sliceOrigin(elem); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// trackSlice records that the variable points into a local array after
+// value is assigned to it or to one of its elements. It is never reset,
+// as the assignment may only happen in some branches.
+func (p *Parser) trackSlice(variable *VarStatement, value Expression) {
+	if _, tracked := p.slices[variable]; tracked {
+		return
+	}
+
+	if name, ok := p.sliceOrigin(value); ok {
+		p.slices[variable] = name
+	}
+}
+
+// localArray returns the local variable holding the array the slice is
+// taken from, the elements of slices are not stored in the variable.
+func (p *Parser) localArray(slice *SliceExpression) (string, bool) {
+	value := slice.Value
+	for {
+		if array := p.types.Array(TypeOf(value)); array != nil && array.Slice {
+			return "", false
+		}
+
+		switch inner := value.(type) {
+		case *Identifier:
+			return inner.Value, !p.scope.isGlobal(inner.Value)
+		case *FieldAccess:
+			value = inner.Value
+		case *IndexExpression:
+			value = inner.Value
+		default:
+			return "", false
+		}
+	}
+}
+
+// parseLenExpression parses the builtin len(x), the current
+// token is the len identifier.
+func (p *Parser) parseLenExpression() (*LenExpression, error) {
+	if err := p.consumeOrFail(lexer.LPAREN); err != nil {
+		return nil, err
+	}
+
+	p.nextToken()
+	value, err := p.parseExpression(LOWEST, Void)
+	if err != nil {
+		return nil, err
+	}
+
+	if valueType := TypeOf(value); !valueType.isArrayOrSlice() && valueType != String {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("len expects an array, a slice or a string, got %s", p.types.Name(valueType)),
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.RPAREN); err != nil {
+		return nil, err
+	}
+	return &LenExpression{Value: value}, nil
+}
+
+// verifyArray checks if st is a value of the array or slice type tt.
+func verifyArray(st Expression, tt Type) error {
+	switch st.(type) {
+	case *Identifier, *FnCall, *FieldAccess, *IndexExpression, *ArrayLiteral, *SliceExpression:
+		if TypeOf(st) == tt {
+			return nil
+		}
+	}
+
+	return ErrWrongTypeAssigment
+}
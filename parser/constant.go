@@ -0,0 +1,500 @@
+package parser
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ErrNotConstant is returned when a constant is initialized
+// with a value that is only known at runtime.
+var ErrNotConstant = errors.New("not a constant expression")
+
+// evaluateConstant folds a constant expression into a single
+// literal of type tt. Constants used by the expression were already
+// replaced by their literals, any other identifier or call is only
+// known at runtime.
+func evaluateConstant(exp Expression, tt Type) (Expression, error) {
+	value, err := foldConstant(exp)
+	if err != nil {
+		return nil, err
+	}
+
+	switch literal := value.(type) {
+	case *IntegerLiteral:
+		if !fitsInteger(literal.Value, tt) {
+			return nil, fmt.Errorf("%d overflows %s", literal.Value, tt)
+		}
+		return &IntegerLiteral{Value: literal.Value, Type: tt}, nil
+	case *FloatLiteral:
+		if !fitsFloat(literal.Value, tt) {
+			return nil, fmt.Errorf("%g overflows %s", literal.Value, tt)
+		}
+		return &FloatLiteral{Value: literal.Value, Type: tt}, nil
+	}
+
+	return value, nil
+}
+
+func foldConstant(exp Expression) (Expression, error) {
+	switch exp := exp.(type) {
+	case *IntegerLiteral, *FloatLiteral, *BooleanLiteral, *StringLiteral, *CharLiteral:
+		return exp, nil
+	case *Identifier:
+		return nil, fmt.Errorf("%w: %s is a variable", ErrNotConstant, exp.Value)
+	case *FnCall:
+		return nil, fmt.Errorf("%w: call to %s", ErrNotConstant, exp.FnName)
+	case *PrefixExpression:
+		right, err := foldConstant(exp.Right)
+		if err != nil {
+			return nil, err
+		}
+		return foldPrefix(exp.Operator, right)
+	case *InfixExpression:
+		left, err := foldConstant(exp.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := foldConstant(exp.Right)
+		if err != nil {
+			return nil, err
+		}
+		return foldInfix(exp.Operator, left, right)
+	case *CastExpression:
+		value, err := foldConstant(exp.Value)
+		if err != nil {
+			return nil, err
+		}
+		return foldCast(exp, value)
+	default:
+		return nil, ErrNotConstant
+	}
+}
+
+func foldPrefix(operator string, right Expression) (Expression, error) {
+	switch right := right.(type) {
+	case *IntegerLiteral:
+		switch operator {
+		case "-":
+			if right.Value == math.MinInt64 {
+				return nil, fmt.Errorf("-(%d) overflows %s", right.Value, right.Type)
+			}
+			return checkedInteger(-right.Value, right.Type)
+		case "~":
+			return &IntegerLiteral{Value: wrapInteger(^right.Value, right.Type), Type: right.Type}, nil
+		}
+	case *FloatLiteral:
+		if operator == "-" {
+			return &FloatLiteral{Value: -right.Value, Type: right.Type}, nil
+		}
+	case *BooleanLiteral:
+		if operator == "!" {
+			return &BooleanLiteral{Value: !right.Value}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: operator %s", ErrNotConstant, operator)
+}
+
+func foldInfix(operator string, left, right Expression) (Expression, error) {
+	switch left := left.(type) {
+	case *IntegerLiteral:
+		if right, ok := right.(*IntegerLiteral); ok {
+			return foldIntegerInfix(operator, left, right)
+		}
+	case *FloatLiteral:
+		if right, ok := right.(*FloatLiteral); ok {
+			return foldFloatInfix(operator, left, right)
+		}
+	case *CharLiteral:
+		if right, ok := right.(*CharLiteral); ok {
+			return foldComparison(operator, cmp.Compare(left.Value, right.Value))
+		}
+	case *BooleanLiteral:
+		if right, ok := right.(*BooleanLiteral); ok {
+			switch operator {
+			case "&&":
+				return &BooleanLiteral{Value: left.Value && right.Value}, nil
+			case "||":
+				return &BooleanLiteral{Value: left.Value || right.Value}, nil
+			case "==":
+				return &BooleanLiteral{Value: left.Value == right.Value}, nil
+			case "!=":
+				return &BooleanLiteral{Value: left.Value != right.Value}, nil
+			}
+		}
+	case *StringLiteral:
+		if right, ok := right.(*StringLiteral); ok && operator == "+" {
+			return &StringLiteral{Value: left.Value + right.Value}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: operator %s", ErrNotConstant, operator)
+}
+
+func foldIntegerInfix(operator string, left, right *IntegerLiteral) (Expression, error) {
+	tt := left.Type
+	if tt == Void {
+		tt = right.Type
+	}
+
+	if tt.IsInteger() && !tt.IsSigned() {
+		return foldUnsignedInfix(operator, uint64(left.Value), uint64(right.Value), tt)
+	}
+
+	if overflowsInt64(operator, left.Value, right.Value) {
+		return nil, fmt.Errorf("%d %s %d overflows %s", left.Value, operator, right.Value, tt)
+	}
+
+	switch operator {
+	case "+":
+		return checkedInteger(left.Value+right.Value, tt)
+	case "-":
+		return checkedInteger(left.Value-right.Value, tt)
+	case "*":
+		return checkedInteger(left.Value*right.Value, tt)
+	case "/", "%":
+		if right.Value == 0 {
+			return nil, errors.New("division by zero")
+		}
+		if operator == "/" {
+			return checkedInteger(left.Value/right.Value, tt)
+		}
+		return &IntegerLiteral{Value: left.Value % right.Value, Type: tt}, nil
+	case "&":
+		return &IntegerLiteral{Value: left.Value & right.Value, Type: tt}, nil
+	case "|":
+		return &IntegerLiteral{Value: left.Value | right.Value, Type: tt}, nil
+	case "^":
+		return &IntegerLiteral{Value: left.Value ^ right.Value, Type: tt}, nil
+	case "<<", ">>":
+		if right.Value < 0 || right.Value >= int64(tt.bitSize()) {
+			return nil, fmt.Errorf("shift count %d out of range", right.Value)
+		}
+		if operator == "<<" {
+			return checkedInteger(left.Value<<right.Value, tt)
+		}
+		return &IntegerLiteral{Value: left.Value >> right.Value, Type: tt}, nil
+	}
+
+	return foldComparison(operator, cmp.Compare(left.Value, right.Value))
+}
+
+// overflowsInt64 reports whether the signed operation wraps around
+// the int64 range, narrower types are checked once the exact result
+// is known.
+func overflowsInt64(operator string, left, right int64) bool {
+	switch operator {
+	case "+":
+		sum := left + right
+		return (left >= 0) == (right >= 0) && (sum >= 0) != (left >= 0)
+	case "-":
+		difference := left - right
+		return (left >= 0) != (right >= 0) && (difference >= 0) != (left >= 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return false
+		}
+		product := left * right
+		return product/right != left || (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64)
+	case "/":
+		return left == math.MinInt64 && right == -1
+	case "<<":
+		return right >= 0 && right < 64 && (left<<right)>>right != left
+	}
+	return false
+}
+
+// foldUnsignedInfix folds an operation over unsigned integers, the
+// values are handled as uint64 so the whole uint64 range is supported.
+func foldUnsignedInfix(operator string, left, right uint64, tt Type) (Expression, error) {
+	var value uint64
+	overflow := false
+
+	switch operator {
+	case "+":
+		value = left + right
+		overflow = value < left
+	case "-":
+		value = left - right
+		overflow = right > left
+	case "*":
+		value = left * right
+		overflow = left != 0 && value/left != right
+	case "/", "%":
+		if right == 0 {
+			return nil, errors.New("division by zero")
+		}
+		value = left / right
+		if operator == "%" {
+			value = left % right
+		}
+	case "&":
+		value = left & right
+	case "|":
+		value = left | right
+	case "^":
+		value = left ^ right
+	case "<<", ">>":
+		if right >= uint64(tt.bitSize()) {
+			return nil, fmt.Errorf("shift count %d out of range", right)
+		}
+		value = left >> right
+		if operator == "<<" {
+			value = left << right
+			overflow = value>>right != left
+		}
+	default:
+		return foldComparison(operator, cmp.Compare(left, right))
+	}
+
+	if overflow || (tt.bitSize() < 64 && value >= 1<<tt.bitSize()) {
+		return nil, fmt.Errorf("%d %s %d overflows %s", left, operator, right, tt)
+	}
+	return &IntegerLiteral{Value: int64(value), Type: tt}, nil
+}
+
+func foldFloatInfix(operator string, left, right *FloatLiteral) (Expression, error) {
+	tt := left.Type
+	if tt == Void {
+		tt = right.Type
+	}
+
+	var value float64
+	switch operator {
+	case "+":
+		value = left.Value + right.Value
+	case "-":
+		value = left.Value - right.Value
+	case "*":
+		value = left.Value * right.Value
+	case "/":
+		if right.Value == 0 {
+			return nil, errors.New("division by zero")
+		}
+		value = left.Value / right.Value
+	default:
+		return foldComparison(operator, cmp.Compare(left.Value, right.Value))
+	}
+
+	if !fitsFloat(value, tt) {
+		return nil, fmt.Errorf("%g overflows %s", value, tt)
+	}
+	return &FloatLiteral{Value: value, Type: tt}, nil
+}
+
+// foldComparison turns the ordering of two operands, as returned by
+// cmp.Compare, into the result of the comparison operator.
+func foldComparison(operator string, order int) (Expression, error) {
+	var result bool
+	switch operator {
+	case "==":
+		result = order == 0
+	case "!=":
+		result = order != 0
+	case "<":
+		result = order < 0
+	case "<=":
+		result = order <= 0
+	case ">":
+		result = order > 0
+	case ">=":
+		result = order >= 0
+	default:
+		return nil, fmt.Errorf("%w: operator %s", ErrNotConstant, operator)
+	}
+
+	return &BooleanLiteral{Value: result}, nil
+}
+
+// foldCast converts a constant like the conversion does at runtime:
+// integers converted to a narrower type wrap and floats converted to
+// integers are truncated toward zero, saturating at the bounds of the
+// integer type. Checked casts fail instead of losing information.
+func foldCast(cast *CastExpression, value Expression) (Expression, error) {
+	target := cast.Type
+
+	var result Expression
+	switch value := value.(type) {
+	case *IntegerLiteral:
+		switch {
+		case target == Char:
+			return &CharLiteral{Value: rune(value.Value)}, nil
+		case target.IsInteger():
+			result = &IntegerLiteral{Value: wrapInteger(value.Value, target), Type: target}
+		case target.IsFloat():
+			number := float64(value.Value)
+			if isUnsigned(value.Type) {
+				number = float64(uint64(value.Value))
+			}
+			result = &FloatLiteral{Value: roundFloat(number, target), Type: target}
+		}
+	case *FloatLiteral:
+		switch {
+		case target.IsFloat():
+			result = &FloatLiteral{Value: roundFloat(value.Value, target), Type: target}
+		case target.IsInteger():
+			result = &IntegerLiteral{Value: saturateInteger(value.Value, target), Type: target}
+		}
+	case *CharLiteral:
+		if target.IsInteger() {
+			return &IntegerLiteral{Value: wrapInteger(int64(value.Value), target), Type: target}, nil
+		}
+	}
+
+	if result == nil {
+		return value, nil
+	}
+
+	if cast.Checked && !sameNumber(value, result) {
+		return nil, fmt.Errorf("checked conversion of %s to %s loses information", formatNumber(value), target)
+	}
+	return result, nil
+}
+
+// roundFloat rounds value to the precision of the float type tt.
+func roundFloat(value float64, tt Type) float64 {
+	if tt == Float32 {
+		return float64(float32(value))
+	}
+	return value
+}
+
+// saturateInteger truncates value toward zero, values out of the range
+// of the integer type tt take its closest bound and NaN becomes zero.
+func saturateInteger(value float64, tt Type) int64 {
+	truncated := math.Trunc(value)
+	bits := tt.bitSize()
+
+	if tt.IsSigned() {
+		low, high := -math.Ldexp(1, bits-1), math.Ldexp(1, bits-1)
+		switch {
+		case math.IsNaN(truncated):
+			return 0
+		case truncated < low:
+			return -1 << (bits - 1)
+		case truncated >= high:
+			return 1<<(bits-1) - 1
+		default:
+			return int64(truncated)
+		}
+	}
+
+	switch {
+	case math.IsNaN(truncated) || truncated < 0:
+		return 0
+	case truncated >= math.Ldexp(1, bits):
+		return wrapInteger(-1, tt)
+	default:
+		return int64(uint64(truncated))
+	}
+}
+
+// sameNumber reports whether two numeric literals hold the same value,
+// NaN floats are the same value.
+func sameNumber(a, b Expression) bool {
+	switch a := a.(type) {
+	case *IntegerLiteral:
+		switch b := b.(type) {
+		case *IntegerLiteral:
+			if isUnsigned(a.Type) != isUnsigned(b.Type) && (a.Value < 0 || b.Value < 0) {
+				return false
+			}
+			return a.Value == b.Value
+		case *FloatLiteral:
+			return sameNumber(b, a)
+		}
+	case *FloatLiteral:
+		switch b := b.(type) {
+		case *IntegerLiteral:
+			// the float is exactly an integer of the range of b
+			if a.Value != math.Trunc(a.Value) {
+				return false
+			}
+
+			if isUnsigned(b.Type) {
+				return a.Value >= 0 && a.Value < math.Ldexp(1, 64) && uint64(a.Value) == uint64(b.Value)
+			}
+			return a.Value >= math.Ldexp(-1, 63) && a.Value < math.Ldexp(1, 63) && int64(a.Value) == b.Value
+		case *FloatLiteral:
+			return a.Value == b.Value || (math.IsNaN(a.Value) && math.IsNaN(b.Value))
+		}
+	}
+
+	return false
+}
+
+// isUnsigned reports whether integer literals of type tt are unsigned,
+// untyped literals are signed.
+func isUnsigned(tt Type) bool {
+	return tt.IsInteger() && !tt.IsSigned()
+}
+
+// formatNumber formats a numeric literal for error messages.
+func formatNumber(literal Expression) string {
+	switch literal := literal.(type) {
+	case *IntegerLiteral:
+		if isUnsigned(literal.Type) {
+			return strconv.FormatUint(uint64(literal.Value), 10)
+		}
+		return strconv.FormatInt(literal.Value, 10)
+	case *FloatLiteral:
+		return strconv.FormatFloat(literal.Value, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// wrapInteger truncates value to the size of tt, sign
+// extending it back to 64 bits for signed integers.
+func wrapInteger(value int64, tt Type) int64 {
+	bits := tt.bitSize()
+	if bits == 64 {
+		return value
+	}
+
+	if tt.IsSigned() {
+		shift := 64 - bits
+		return value << shift >> shift
+	}
+	return value & (1<<bits - 1)
+}
+
+// checkedInteger fails if the result of a constant
+// operation does not fit in its integer type.
+func checkedInteger(value int64, tt Type) (Expression, error) {
+	if !fitsInteger(value, tt) {
+		return nil, fmt.Errorf("%d overflows %s", value, tt)
+	}
+	return &IntegerLiteral{Value: value, Type: tt}, nil
+}
+
+// fitsFloat reports whether value is in the range of the float type tt,
+// float literals without a type are float32.
+func fitsFloat(value float64, tt Type) bool {
+	if tt == Float64 {
+		return !math.IsInf(value, 0)
+	}
+	return math.Abs(value) <= math.MaxFloat32
+}
+
+// fitsInteger reports whether value is in the range of the integer
+// type tt, uint64 values above the int64 range are stored wrapped.
+func fitsInteger(value int64, tt Type) bool {
+	if tt == Void {
+		tt = Int32
+	}
+
+	bits := tt.bitSize()
+	switch {
+	case tt.IsSigned():
+		return value >= -1<<(bits-1) && value <= 1<<(bits-1)-1
+	case bits == 64:
+		return true
+	default:
+		return value >= 0 && value < 1<<bits
+	}
+}
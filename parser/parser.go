@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/EclesioMeloJunior/lotus/lexer"
 )
@@ -32,13 +36,19 @@ type Expression interface {
 // Program represents the root node of the AST.
 type Program struct {
 	Statements []Node
+	// Types describes the struct, array and slice
+	// types used by the statements
+	Types *Types
 }
 
-// VarStatement represents a variable declaration.
+// VarStatement represents a variable declaration. Constants are
+// variables whose Value is a literal known at compile time.
 type VarStatement struct {
 	Name  string
 	Value Expression
 	Type  Type
+	Doc   string
+	Const bool
 }
 
 type ReassignVarStatement struct {
@@ -67,6 +77,7 @@ type FnStatement struct {
 	ReturnType            Type
 	Defined               bool
 	ExpressionsToEvaluate []Expression
+	Doc                   string
 }
 
 type FnCall struct {
@@ -98,16 +109,46 @@ type StringLiteral struct {
 
 func (*StringLiteral) expressionNode() {}
 
-// IntegerLiteral represents an integer literal.
+// CharLiteral represents a character literal, e.g: 'a'
+type CharLiteral struct {
+	Value rune
+}
+
+func (*CharLiteral) expressionNode() {}
+
+// BooleanLiteral represents the true and false literals.
+type BooleanLiteral struct {
+	Value bool
+}
+
+func (*BooleanLiteral) expressionNode() {}
+
+// CastExpression converts Value from the type From to the type Type,
+// written with the as operator e.g: count as uint8. Checked casts,
+// written as!, trap at runtime instead of losing information.
+type CastExpression struct {
+	Value   Expression
+	From    Type
+	Type    Type
+	Checked bool
+}
+
+func (*CastExpression) expressionNode() {}
+
+// IntegerLiteral represents an integer literal, Type
+// is only set when the literal has a type suffix.
 type IntegerLiteral struct {
 	Value int64
+	Type  Type
 }
 
 func (*IntegerLiteral) expressionNode() {}
 
-// FloatLiteral represents a float literal.
+// FloatLiteral represents a float literal, Type
+// is only set when the literal has a type suffix.
 type FloatLiteral struct {
 	Value float64
+	Type  Type
 }
 
 func (*FloatLiteral) expressionNode() {}
@@ -140,10 +181,21 @@ type Parser struct {
 	curToken  lexer.Token
 	peekToken lexer.Token
 
-	// TODO: currently all variables are "global" in the
-	// parser's pov
-	vars map[string]*VarStatement
-	fns  map[string]*FnStatement
+	// scope is the innermost scope of the statement being parsed
+	scope   *scope
+	fns     map[string]*FnStatement
+	structs map[string]*StructStatement
+	types   *Types
+
+	// slices holds the local array each variable points
+	// into, once it is assigned a slice of it
+	slices map[*VarStatement]string
+
+	errors ErrList
+
+	// loops holds the labels of the enclosing loops, innermost
+	// last, unlabeled loops have an empty label
+	loops []string
 }
 
 // NewParser returns a new instance of Parser.
@@ -152,9 +204,12 @@ func NewParser(tokens iter.Seq[lexer.Token]) *Parser {
 	tokenStream := &TokenStream{next: next, stop: stop}
 
 	p := &Parser{
-		tokens: tokenStream,
-		vars:   map[string]*VarStatement{},
-		fns:    map[string]*FnStatement{},
+		tokens:  tokenStream,
+		scope:   newScope(nil),
+		fns:     map[string]*FnStatement{},
+		structs: map[string]*StructStatement{},
+		types:   &Types{},
+		slices:  map[*VarStatement]string{},
 	}
 	p.nextToken()
 	p.nextToken() // read two tokens, so curToken and peekToken are both set
@@ -172,42 +227,154 @@ func (e *ErrParser) Error() string {
 	return fmt.Sprintf("Error at line %d, column %d: %s", e.Line, e.Column, e.Err.Error())
 }
 
-// ParseProgram parses the tokens and returns a Program node.
+func (e *ErrParser) Unwrap() error {
+	return e.Err
+}
+
+// ErrList holds every error found while parsing a program, in source order.
+type ErrList []*ErrParser
+
+func (l ErrList) Error() string {
+	msgs := make([]string, len(l))
+	for idx, err := range l {
+		msgs[idx] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for idx, err := range l {
+		errs[idx] = err
+	}
+	return errs
+}
+
+// ParseProgram parses the tokens and returns a Program node. Parsing
+// goes on after an error, so all of them are returned as an ErrList
+// along with the statements that could be parsed.
 func (p *Parser) ParseProgram() (*Program, error) {
-	program := &Program{}
+	program := &Program{Types: p.types}
 	for p.curToken.Type != lexer.EOF {
-		if p.curToken.Type == lexer.NEXTLINE {
+		// empty statement
+		if p.curToken.Type == lexer.SEMICOLON {
 			p.nextToken()
 			continue
 		}
 
 		stmt, err := p.parseStatement(Void)
 		if err != nil {
-			return nil, err
+			p.report(err)
+			p.synchronize()
+
+			// a fn keyword starts the next statement
+			if p.curToken.Type != lexer.FN {
+				p.nextToken()
+			}
+			continue
 		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+
+	if len(p.errors) > 0 {
+		return program, p.errors
+	}
 	return program, nil
 }
 
+// report records an error, errors without a position
+// are reported at the current token.
+func (p *Parser) report(err error) {
+	var parserErr *ErrParser
+	if !errors.As(err, &parserErr) {
+		parserErr = &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    err,
+		}
+	}
+	p.errors = append(p.errors, parserErr)
+}
+
+// synchronize skips the tokens of a statement with errors, stopping at
+// its terminating semicolon, at the closing brace of the enclosing block
+// or at a fn keyword. Nested blocks are skipped as a whole.
+func (p *Parser) synchronize() {
+	depth := 0
+	for {
+		switch p.curToken.Type {
+		case lexer.EOF, lexer.FN:
+			return
+		case lexer.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		case lexer.LBRACE:
+			depth++
+		case lexer.RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement(tt Type) (Node, error) {
 	switch p.curToken.Type {
 	case lexer.VAR:
 		return p.parseVarStatement()
+	case lexer.CONST:
+		return p.parseConstStatement()
 	case lexer.FN:
 		return p.parseFnStatement()
+	case lexer.STRUCT:
+		return p.parseStructStatement()
 	case lexer.RETURN:
 		return p.parseReturnStatement(tt)
+	case lexer.IF:
+		if err := p.checkInsideFunction(p.curToken.Literal); err != nil {
+			return nil, err
+		}
+		return p.parseIfStatement(tt)
+	case lexer.WHILE, lexer.LOOP:
+		if err := p.checkInsideFunction(p.curToken.Literal); err != nil {
+			return nil, err
+		}
+		return p.parseWhileStatement("", tt)
+	case lexer.FOR:
+		if err := p.checkInsideFunction(p.curToken.Literal); err != nil {
+			return nil, err
+		}
+		return p.parseForStatement("", tt)
+	case lexer.BREAK, lexer.CONTINUE:
+		return p.parseBranchStatement()
 	case lexer.IDENT:
-		varStmt, exists := p.vars[p.curToken.Literal]
+		if p.peekTokenIs(lexer.COLON) {
+			if err := p.checkInsideFunction("labeled"); err != nil {
+				return nil, err
+			}
+			return p.parseLabeledStatement(tt)
+		}
+
+		varStmt, exists := p.scope.lookup(p.curToken.Literal)
 		// we are reassining a new value to the a already defined variable
 		if exists {
 			return p.parseReasignStatement(varStmt)
 		}
 
+		if !p.peekTokenIs(lexer.LPAREN) {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("%w: %s", ErrVariableUndefined, p.curToken.Literal),
+			}
+		}
+
 		ident := &UnboundedIdentifier{Value: p.curToken.Literal}
 		if err := p.consumeOrFail(lexer.LPAREN); err != nil {
 			return nil, err
@@ -219,13 +386,8 @@ func (p *Parser) parseStatement(tt Type) (Node, error) {
 			return nil, err
 		}
 
-		p.nextToken()
-		if !endOfStatement(p.curToken.Type) {
-			return nil, &ErrParser{
-				Line:   p.curToken.Line,
-				Column: p.curToken.Column + len(p.curToken.Literal),
-				Err:    fmt.Errorf("after %s: expected end of statement or new line", p.curToken.Literal),
-			}
+		if err := p.endStatement(); err != nil {
+			return nil, err
 		}
 		return expr, nil
 	default:
@@ -238,30 +400,29 @@ func (p *Parser) parseStatement(tt Type) (Node, error) {
 }
 
 func (p *Parser) parseVarStatement() (*VarStatement, error) {
-	stmt := &VarStatement{}
+	stmt := &VarStatement{Doc: p.curToken.Doc}
 
 	if err := p.consumeOrFail(lexer.IDENT); err != nil {
 		return nil, err
 	}
 	stmt.Name = p.curToken.Literal
+	nameToken := p.curToken
 
 	shouldInfer := true
 	if p.peekToken.Type == lexer.COLON {
 		p.nextToken()
-		// TODO: should support custom types
-		if err := p.consumeOrFail(lexer.RAWTYPE); err != nil {
+
+		var err error
+		if stmt.Type, err = p.parseType(); err != nil {
 			return nil, err
 		}
-
-		stmt.Type = getTypeFromLiteral(p.curToken.Literal)
 		shouldInfer = false
 	}
 
 	if endOfStatement(p.peekToken.Type) {
-		p.nextToken()
+		p.expectPeek(lexer.SEMICOLON)
 		stmt.Value = nil
-		p.vars[stmt.Name] = stmt
-		return stmt, nil
+		return stmt, p.declare(stmt, nameToken)
 	}
 
 	if err := p.consumeOrFail(lexer.ASSIGN); err != nil {
@@ -275,9 +436,15 @@ func (p *Parser) parseVarStatement() (*VarStatement, error) {
 	}
 
 	stmt.Value = expression
+	p.trackSlice(stmt, expression)
 
 	if shouldInfer {
 		stmt.Type, err = p.inferTypeFromExpression(stmt.Value)
+		if err == nil {
+			// untyped literals take the inferred type
+			stmt.Value, err = convertExpression(stmt.Value, stmt.Type)
+		}
+
 		if err != nil {
 			return nil, &ErrParser{
 				Line:   p.curToken.Line,
@@ -287,33 +454,197 @@ func (p *Parser) parseVarStatement() (*VarStatement, error) {
 		}
 	}
 
-	if !endOfStatement(p.peekToken.Type) {
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+
+	// declared after its value, so the value
+	// still refers to any shadowed variable
+	return stmt, p.declare(stmt, nameToken)
+}
+
+// parseConstStatement parses a constant declaration, the value is
+// evaluated at compile time and the resulting literal replaces the
+// constant wherever it is used.
+func (p *Parser) parseConstStatement() (*VarStatement, error) {
+	stmt := &VarStatement{Doc: p.curToken.Doc, Const: true}
+
+	if err := p.consumeOrFail(lexer.IDENT); err != nil {
+		return nil, err
+	}
+	stmt.Name = p.curToken.Literal
+	nameToken := p.curToken
+
+	shouldInfer := true
+	if p.peekToken.Type == lexer.COLON {
+		p.nextToken()
+
+		var err error
+		if stmt.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		shouldInfer = false
+	}
+
+	if endOfStatement(p.peekToken.Type) {
 		return nil, &ErrParser{
-			Line:   p.curToken.Line,
-			Column: p.curToken.Column + len(p.curToken.Literal),
-			Err:    fmt.Errorf("after %s: expected end of statement or new line", p.curToken.Literal),
+			Line:   nameToken.Line,
+			Column: nameToken.Column,
+			Err:    fmt.Errorf("constant %s must be initialized", stmt.Name),
 		}
 	}
 
-	p.vars[stmt.Name] = stmt
+	if err := p.consumeOrFail(lexer.ASSIGN); err != nil {
+		return nil, err
+	}
 
 	p.nextToken()
-	return stmt, nil
+	valueToken := p.curToken
+	expression, err := p.parseExpression(LOWEST, stmt.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if shouldInfer {
+		stmt.Type, err = p.inferTypeFromExpression(expression)
+		if err == nil {
+			// untyped literals take the inferred type
+			expression, err = convertExpression(expression, stmt.Type)
+		}
+
+		if err != nil {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column + len(p.curToken.Literal),
+				Err:    err,
+			}
+		}
+	}
+
+	stmt.Value, err = evaluateConstant(expression, stmt.Type)
+	if err != nil {
+		return nil, &ErrParser{
+			Line:   valueToken.Line,
+			Column: valueToken.Column,
+			Err:    fmt.Errorf("constant %s: %w", stmt.Name, err),
+		}
+	}
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+
+	return stmt, p.declare(stmt, nameToken)
 }
 
-func (p *Parser) parseReasignStatement(old *VarStatement) (*ReassignVarStatement, error) {
-	if err := p.consumeOrFail(lexer.ASSIGN); err != nil {
+// declare adds the variable to the current scope, failing
+// if the scope already has a variable with the same name.
+func (p *Parser) declare(stmt *VarStatement, name lexer.Token) error {
+	if !p.scope.declare(stmt) {
+		return &ErrParser{
+			Line:   name.Line,
+			Column: name.Column,
+			Err:    fmt.Errorf("variable %s already declared in this scope", stmt.Name),
+		}
+	}
+	return nil
+}
+
+func (p *Parser) parseReasignStatement(old *VarStatement) (Node, error) {
+	if old.Const {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("cannot assign to constant %s", old.Name),
+		}
+	}
+
+	if p.peekTokenIs(lexer.DOT) || p.peekTokenIs(lexer.LBRACKET) {
+		return p.parseAssignElementStatement(old)
+	}
+
+	value, err := p.parseAssignment(&Identifier{Value: old.Name, Type: old.Type}, old.Type)
+	if err != nil {
 		return nil, err
 	}
+	p.trackSlice(old, value)
 
-	reasign := &ReassignVarStatement{
+	return &ReassignVarStatement{
 		VarName: old.Name,
 		Type:    old.Type,
+		Value:   value,
+	}, nil
+}
+
+// parseAssignElementStatement parses an assignment to a field or an
+// element of the variable, e.g: p.x = 3, a[i] = 3 or a[i].x = 3,
+// compound assignments are supported.
+func (p *Parser) parseAssignElementStatement(variable *VarStatement) (Node, error) {
+	var target Expression = &Identifier{Value: variable.Name, Type: variable.Type}
+	for p.peekTokenIs(lexer.DOT) || p.peekTokenIs(lexer.LBRACKET) {
+		p.nextToken()
+
+		var err error
+		if p.curToken.Type == lexer.DOT {
+			target, err = p.parseFieldAccess(target)
+		} else {
+			target, err = p.parseIndexExpression(target)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch target := target.(type) {
+	case *FieldAccess:
+		value, err := p.parseAssignment(target, target.Type)
+		if err != nil {
+			return nil, err
+		}
+		p.trackSlice(variable, value)
+		return &AssignFieldStatement{Target: target, Value: value}, nil
+	case *IndexExpression:
+		value, err := p.parseAssignment(target, target.Type)
+		if err != nil {
+			return nil, err
+		}
+		p.trackSlice(variable, value)
+		return &AssignIndexStatement{Target: target, Value: value}, nil
+	default:
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    errors.New("cannot assign to a sub-slice"),
+		}
+	}
+}
+
+// parseAssignment parses the assignment to target, a variable, a field
+// or an element of type tt ending at the current token, and returns the
+// value assigned to it.
+func (p *Parser) parseAssignment(target Expression, tt Type) (Expression, error) {
+	switch {
+	case p.peekTokenIs(lexer.INC) || p.peekTokenIs(lexer.DEC):
+		return p.parseIncDec(target, tt)
+	case compoundOperators[p.peekToken.Type] != "":
+		return p.parseCompoundAssignment(target, tt)
+	}
+
+	if err := p.consumeOrFail(lexer.ASSIGN); err != nil {
+		return nil, err
 	}
 
 	p.nextToken()
-	newExp, err := p.parseExpression(LOWEST, old.Type)
+	valueToken := p.curToken
+	value, err := p.parseExpression(LOWEST, tt)
 	if err != nil {
+		// errors already located keep their position
+		var parserErr *ErrParser
+		if errors.As(err, &parserErr) {
+			return nil, err
+		}
+
 		return nil, &ErrParser{
 			Line:   p.curToken.Line,
 			Column: p.curToken.Column + len(p.curToken.Literal),
@@ -321,22 +652,144 @@ func (p *Parser) parseReasignStatement(old *VarStatement) (*ReassignVarStatement
 		}
 	}
 
-	reasign.Value = newExp
+	// globals outlive the function assigning them
+	if p.isGlobalTarget(target) {
+		if err := p.checkSliceEscape(value); err != nil {
+			return nil, &ErrParser{
+				Line:   valueToken.Line,
+				Column: valueToken.Column,
+				Err:    err,
+			}
+		}
+	}
 
-	if !endOfStatement(p.peekToken.Type) {
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// isGlobalTarget reports whether the assignment target is a global
+// variable, or a field or an element of one.
+func (p *Parser) isGlobalTarget(target Expression) bool {
+	for {
+		switch inner := target.(type) {
+		case *Identifier:
+			return p.scope.isGlobal(inner.Value)
+		case *FieldAccess:
+			target = inner.Value
+		case *IndexExpression:
+			target = inner.Value
+		default:
+			return false
+		}
+	}
+}
+
+// compoundOperators maps compound assignment tokens to
+// the infix operator they apply, e.g: += applies +
+var compoundOperators = map[lexer.TokenType]string{
+	lexer.PLUS_ASSIGN:    "+",
+	lexer.MINUS_ASSIGN:   "-",
+	lexer.STAR_ASSIGN:    "*",
+	lexer.SLASH_ASSIGN:   "/",
+	lexer.PERCENT_ASSIGN: "%",
+}
+
+// parseCompoundAssignment parses `x op= value` as the
+// assignment `x = x op value`, the value is parsed as a whole
+// so `x *= a + b` multiplies x by the sum.
+func (p *Parser) parseCompoundAssignment(target Expression, tt Type) (Expression, error) {
+	p.nextToken()
+	operatorToken := p.curToken
+
+	operator := compoundOperators[operatorToken.Type]
+	if err := verifyCompoundAssignment(operator, tt); err != nil {
+		return nil, &ErrParser{
+			Line:   operatorToken.Line,
+			Column: operatorToken.Column,
+			Err:    fmt.Errorf("operator %s: %w", operatorToken.Literal, err),
+		}
+	}
+
+	p.nextToken()
+	value, err := p.parseExpression(LOWEST, tt)
+	if err != nil {
+		return nil, err
+	}
+
+	expression := &InfixExpression{
+		Left:     target,
+		Operator: operator,
+		Right:    value,
+	}
+
+	if err := tt.Verify(expression); err != nil {
 		return nil, &ErrParser{
 			Line:   p.curToken.Line,
-			Column: p.curToken.Column + len(p.curToken.Literal),
-			Err:    fmt.Errorf("after %s: expected end of statement or new line", p.curToken.Literal),
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("verifying expression: %w", err),
 		}
 	}
 
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+
+	return expression, nil
+}
+
+// parseIncDec parses the postfix `x++` and `x--` statements
+// as the assignments `x = x + 1` and `x = x - 1`.
+func (p *Parser) parseIncDec(target Expression, tt Type) (Expression, error) {
 	p.nextToken()
-	return reasign, nil
+
+	operator := "+"
+	if p.curToken.Type == lexer.DEC {
+		operator = "-"
+	}
+
+	if err := verifyCompoundAssignment(operator, tt); err != nil {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("operator %s: %w", p.curToken.Literal, err),
+		}
+	}
+
+	var one Expression = &IntegerLiteral{Value: 1, Type: tt}
+	if tt.IsFloat() {
+		one = &FloatLiteral{Value: 1, Type: tt}
+	}
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+
+	return &InfixExpression{
+		Left:     target,
+		Operator: operator,
+		Right:    one,
+	}, nil
+}
+
+// checkInsideFunction fails when a control flow statement of the given
+// kind is at the top level, the program only holds declarations and the
+// assignments run by the static initializer.
+func (p *Parser) checkInsideFunction(kind string) error {
+	if p.scope.outer != nil {
+		return nil
+	}
+
+	return &ErrParser{
+		Line:   p.curToken.Line,
+		Column: p.curToken.Column,
+		Err:    fmt.Errorf("%s statements can only be used inside functions", kind),
+	}
 }
 
 func (p *Parser) parseFnStatement() (*FnStatement, error) {
-	stmt := &FnStatement{}
+	stmt := &FnStatement{Doc: p.curToken.Doc}
 
 	if err := p.consumeOrFail(lexer.IDENT); err != nil {
 		return nil, err
@@ -344,6 +797,14 @@ func (p *Parser) parseFnStatement() (*FnStatement, error) {
 
 	stmt.Name = p.curToken.Literal
 
+	if stmt.Name == "len" {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    errors.New("len is a builtin function"),
+		}
+	}
+
 	if fnStmt, exists := p.fns[stmt.Name]; exists {
 		if fnStmt.Defined {
 			return nil, &ErrParser{
@@ -376,11 +837,10 @@ func (p *Parser) parseFnStatement() (*FnStatement, error) {
 			return nil, err
 		}
 
-		if err := p.consumeOrFail(lexer.RAWTYPE); err != nil {
+		var err error
+		if arg.Type, err = p.parseType(); err != nil {
 			return nil, err
 		}
-
-		arg.Type = getTypeFromLiteral(p.curToken.Literal)
 		stmt.Args = append(stmt.Args, arg)
 
 		if p.peekToken.Type == lexer.RPAREN {
@@ -417,155 +877,680 @@ func (p *Parser) parseFnStatement() (*FnStatement, error) {
 	if p.peekToken.Type == lexer.COLON {
 		mustHaveReturn = true
 		p.nextToken()
-		// TODO: should support custom types
-		if err := p.consumeOrFail(lexer.RAWTYPE); err != nil {
+
+		var err error
+		if stmt.ReturnType, err = p.parseType(); err != nil {
 			return nil, err
 		}
+	}
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	// parameters are declared in a scope enclosing the body
+	params := newScope(p.scope)
+	for _, arg := range stmt.Args {
+		if !params.declare(&VarStatement{Name: arg.Name, Type: arg.Type}) {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("duplicate parameter %s", arg.Name),
+			}
+		}
+	}
+
+	p.scope = params
+	errCount := len(p.errors)
+	body, err := p.parseBlock(stmt.ReturnType)
+	p.scope = params.outer
+	if err != nil {
+		return nil, err
+	}
+	stmt.Body = body
+
+	if mustHaveReturn {
+		// a missing return is likely caused by the errors in the body
+		if !endsWithReturn(stmt.Body) && len(p.errors) == errCount {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    errors.New("function must have a return"),
+			}
+		}
+	} else {
+		stmt.Body = append(stmt.Body, &ReturnStatement{
+			Type: Void,
+		})
+	}
+
+	p.fns[stmt.Name] = stmt
+	return stmt, nil
+}
+
+// ReturnStatement represents a return statement.
+type ReturnStatement struct {
+	Type  Type
+	Value Expression
+}
+
+// parseReturnStatement parses a return statement.
+func (p *Parser) parseReturnStatement(tt Type) (*ReturnStatement, error) {
+	stmt := &ReturnStatement{Type: tt}
+
+	// a bare return, only allowed in functions without a return type
+	if endOfStatement(p.peekToken.Type) {
+		if tt != Void {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("missing return value of type %s", p.types.Name(tt)),
+			}
+		}
+
+		p.expectPeek(lexer.SEMICOLON)
+		return stmt, nil
+	}
+
+	returnToken := p.curToken
+	p.nextToken()
+	expression, err := p.parseExpression(LOWEST, tt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.checkSliceEscape(expression); err != nil {
+		return nil, &ErrParser{
+			Line:   returnToken.Line,
+			Column: returnToken.Column,
+			Err:    err,
+		}
+	}
+
+	stmt.Value = expression
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// IfStatement represents an if statement, an else if is
+// stored as an IfStatement being the only alternative node.
+type IfStatement struct {
+	Condition   Expression
+	Consequence []Node
+	// Alternative is nil when there is no else branch
+	Alternative []Node
+}
+
+// parseIfStatement parses an if statement with its optional else
+// branches, tt is the return type of the enclosing function.
+func (p *Parser) parseIfStatement(tt Type) (*IfStatement, error) {
+	stmt := &IfStatement{}
+
+	p.nextToken()
+	condition, err := p.parseExpression(LOWEST, Bool)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Condition = condition
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	stmt.Consequence, err = p.parseBlock(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.expectPeek(lexer.ELSE) {
+		p.nextToken()
+		switch p.curToken.Type {
+		case lexer.IF:
+			elseIf, err := p.parseIfStatement(tt)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Alternative = []Node{elseIf}
+			return stmt, nil
+		case lexer.LBRACE:
+			stmt.Alternative, err = p.parseBlock(tt)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("expected: if or {, got: %s", p.curToken.Type.String()),
+			}
+		}
+	}
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// WhileStatement represents a while loop, `loop { }` is
+// parsed as a while statement without condition.
+type WhileStatement struct {
+	Label string
+	// Condition is nil for infinite loops
+	Condition Expression
+	Body      []Node
+}
+
+// ForStatement represents a loop over an integer range,
+// `for i in start..end step n` or `for i in start..=end`.
+type ForStatement struct {
+	Label string
+	// Variable is the induction variable, only visible in the body
+	Variable  *VarStatement
+	Start     Expression
+	End       Expression
+	Inclusive bool
+	// Step is always positive, 1 when not given
+	Step int64
+	Body []Node
+}
+
+// BreakStatement exits the loop with the given label,
+// or the innermost one when the label is empty.
+type BreakStatement struct {
+	Label string
+}
+
+// ContinueStatement starts the next iteration of the loop with
+// the given label, or the innermost one when the label is empty.
+type ContinueStatement struct {
+	Label string
+}
+
+// parseLabeledStatement parses a loop preceded by `label:`.
+func (p *Parser) parseLabeledStatement(tt Type) (Node, error) {
+	label := p.curToken.Literal
+	if slices.Contains(p.loops, label) {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("label %s already defined", label),
+		}
+	}
+
+	p.nextToken()
+	p.nextToken()
+	switch p.curToken.Type {
+	case lexer.WHILE, lexer.LOOP:
+		return p.parseWhileStatement(label, tt)
+	case lexer.FOR:
+		return p.parseForStatement(label, tt)
+	default:
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("expected loop after label %s, got: %s", label, p.curToken.Type.String()),
+		}
+	}
+}
+
+// parseWhileStatement parses `while cond { }` and `loop { }`.
+func (p *Parser) parseWhileStatement(label string, tt Type) (*WhileStatement, error) {
+	stmt := &WhileStatement{Label: label}
+
+	if p.curToken.Type == lexer.WHILE {
+		p.nextToken()
+		condition, err := p.parseExpression(LOWEST, Bool)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Condition = condition
+	}
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	p.loops = append(p.loops, label)
+	body, err := p.parseBlock(tt)
+	p.loops = p.loops[:len(p.loops)-1]
+	if err != nil {
+		return nil, err
+	}
+	stmt.Body = body
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseForStatement parses a range loop, `step` is only
+// a keyword after the end of the range.
+func (p *Parser) parseForStatement(label string, tt Type) (*ForStatement, error) {
+	stmt := &ForStatement{Label: label, Step: 1}
+
+	if err := p.consumeOrFail(lexer.IDENT); err != nil {
+		return nil, err
+	}
+	stmt.Variable = &VarStatement{Name: p.curToken.Literal}
+
+	if err := p.consumeOrFail(lexer.IN); err != nil {
+		return nil, err
+	}
+
+	p.nextToken()
+	rangeToken := p.curToken
+	start, err := p.parseExpression(LOWEST, Void)
+	if err != nil {
+		return nil, err
+	}
+
+	p.nextToken()
+	switch p.curToken.Type {
+	case lexer.DOTDOT:
+	case lexer.DOTDOT_EQ:
+		stmt.Inclusive = true
+	default:
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("expected: .. or ..=, got: %s", p.curToken.Type.String()),
+		}
+	}
+
+	p.nextToken()
+	end, err := p.parseExpression(LOWEST, Void)
+	if err != nil {
+		return nil, err
+	}
+
+	// the induction variable takes the type of the bounds
+	stmt.Variable.Type, err = p.rangeType(start, end)
+	if err == nil {
+		stmt.Start, err = convertExpression(start, stmt.Variable.Type)
+	}
+
+	if err == nil {
+		stmt.End, err = convertExpression(end, stmt.Variable.Type)
+	}
+
+	if err != nil {
+		return nil, &ErrParser{
+			Line:   rangeToken.Line,
+			Column: rangeToken.Column,
+			Err:    err,
+		}
+	}
+
+	if p.peekTokenIs(lexer.IDENT) && p.peekToken.Literal == "step" {
+		p.nextToken()
+		if err := p.consumeOrFail(lexer.INT); err != nil {
+			return nil, err
+		}
+
+		step, err := p.parseNumericLiteral(stmt.Variable.Type, false)
+		if err != nil {
+			return nil, err
+		}
+
+		literal, ok := step.(*IntegerLiteral)
+		if !ok || literal.Value <= 0 {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    errors.New("range step must be a positive integer"),
+			}
+		}
+		stmt.Step = literal.Value
+	}
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	// the induction variable lives in its own scope around the body
+	p.scope = newScope(p.scope)
+	p.scope.declare(stmt.Variable)
+	p.loops = append(p.loops, label)
+
+	body, err := p.parseBlock(tt)
+
+	p.loops = p.loops[:len(p.loops)-1]
+	p.scope = p.scope.outer
+
+	if err != nil {
+		return nil, err
+	}
+	stmt.Body = body
+
+	if err := p.endStatement(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// rangeType returns the type of the bounds of a range, the narrower
+// bound is widened and bounds made only of untyped integer literals
+// are int32.
+func (p *Parser) rangeType(start, end Expression) (Type, error) {
+	startType, endType := TypeOf(start), TypeOf(end)
+
+	var tt Type
+	switch {
+	case startType == Void && endType == Void:
+		tt = Int32
+	case startType == Void || canWiden(startType, endType):
+		tt = endType
+	default:
+		tt = startType
+	}
+
+	if !tt.IsInteger() || tt.Verify(start) != nil || tt.Verify(end) != nil {
+		return Void, fmt.Errorf("range bounds: mismatched or not integer types %s and %s", p.types.Name(startType), p.types.Name(endType))
+	}
+	return tt, nil
+}
+
+// parseBranchStatement parses break and continue, both
+// must be inside a loop matching the optional label.
+func (p *Parser) parseBranchStatement() (Node, error) {
+	keyword := p.curToken
+
+	var label string
+	if p.expectPeek(lexer.IDENT) {
+		label = p.curToken.Literal
+	}
 
-		stmt.ReturnType = getTypeFromLiteral(p.curToken.Literal)
+	switch {
+	case len(p.loops) == 0:
+		return nil, &ErrParser{
+			Line:   keyword.Line,
+			Column: keyword.Column,
+			Err:    fmt.Errorf("%s outside of a loop", keyword.Literal),
+		}
+	case label != "" && !slices.Contains(p.loops, label):
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("undefined loop label: %s", label),
+		}
 	}
 
-	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+	if err := p.endStatement(); err != nil {
 		return nil, err
 	}
 
-	stmt.Body = []Node{}
-	p.nextToken()
-	for p.curToken.Type != lexer.RBRACE {
-		if p.curToken.Type == lexer.NEXTLINE {
-			p.nextToken()
-			continue
-		}
+	if keyword.Type == lexer.BREAK {
+		return &BreakStatement{Label: label}, nil
+	}
+	return &ContinueStatement{Label: label}, nil
+}
 
-		innerStmt, err := p.parseStatement(stmt.ReturnType)
-		if err != nil {
-			return nil, err
-		}
-		if innerStmt != nil {
-			stmt.Body = append(stmt.Body, innerStmt)
-		}
+// parseBlock parses the statements between braces in a new scope, the
+// current token must be the opening brace and the closing one is left
+// as current.
+func (p *Parser) parseBlock(tt Type) ([]Node, error) {
+	block := []Node{}
 
-		p.nextToken()
-	}
+	p.scope = newScope(p.scope)
+	defer func() { p.scope = p.scope.outer }()
 
-	if mustHaveReturn {
-		if len(stmt.Body) == 0 {
+	p.nextToken()
+	for p.curToken.Type != lexer.RBRACE {
+		// functions cannot be nested, so the block was not closed
+		if p.curToken.Type == lexer.EOF || p.curToken.Type == lexer.FN {
 			return nil, &ErrParser{
 				Line:   p.curToken.Line,
 				Column: p.curToken.Column,
-				Err:    errors.New("function must have a return"),
+				Err:    fmt.Errorf("expected: }, got: %s", p.curToken.Type.String()),
 			}
 		}
 
-		switch inner := stmt.Body[len(stmt.Body)-1].(type) {
-		case *ReturnStatement:
-			if inner.Type != stmt.ReturnType {
-				return nil, &ErrParser{
-					Line:   p.curToken.Line,
-					Column: p.curToken.Column,
-					Err:    fmt.Errorf("function expected return type: %T", stmt.ReturnType),
-				}
-			}
-		default:
-			return nil, &ErrParser{
-				Line:   p.curToken.Line,
-				Column: p.curToken.Column,
-				Err:    errors.New("function must have a return"),
+		if p.curToken.Type == lexer.SEMICOLON {
+			p.nextToken()
+			continue
+		}
+
+		stmt, err := p.parseStatement(tt)
+		if err != nil {
+			// recover at the end of the statement and keep
+			// parsing the rest of the block
+			p.report(err)
+			p.synchronize()
+			if p.curToken.Type == lexer.SEMICOLON {
+				p.nextToken()
 			}
+			continue
+		}
+		if stmt != nil {
+			block = append(block, stmt)
 		}
-	} else {
-		stmt.Body = append(stmt.Body, &ReturnStatement{
-			Type: Void,
-		})
-	}
 
-	p.fns[stmt.Name] = stmt
-	return stmt, nil
-}
+		p.nextToken()
+	}
 
-// ReturnStatement represents a return statement.
-type ReturnStatement struct {
-	Type  Type
-	Value Expression
+	return block, nil
 }
 
-// parseReturnStatement parses a return statement.
-func (p *Parser) parseReturnStatement(tt Type) (*ReturnStatement, error) {
-	stmt := &ReturnStatement{}
-	p.nextToken()
-	expression, err := p.parseExpression(LOWEST, tt)
-	if err != nil {
-		return nil, err
+// endsWithReturn reports whether every path through the
+// statements ends in a return statement.
+func endsWithReturn(stmts []Node) bool {
+	if len(stmts) == 0 {
+		return false
 	}
 
-	stmt.Type = tt
-	stmt.Value = expression
+	switch last := stmts[len(stmts)-1].(type) {
+	case *ReturnStatement:
+		return true
+	case *IfStatement:
+		return last.Alternative != nil &&
+			endsWithReturn(last.Consequence) &&
+			endsWithReturn(last.Alternative)
+	case *WhileStatement:
+		// an infinite loop can only be left by a return
+		return last.Condition == nil && !breaksOut(last.Body, last.Label, true)
+	default:
+		return false
+	}
+}
 
-	if !endOfStatement(p.peekToken.Type) {
-		return nil, &ErrParser{
-			Line:   p.curToken.Line,
-			Column: p.curToken.Column + len(p.curToken.Literal),
-			Err:    fmt.Errorf("after %s: expected end of statement or new line", p.curToken.Literal),
+// breaksOut reports whether the statements contain a break exiting the
+// loop with the given label, unlabeled breaks only exit the innermost loop.
+func breaksOut(stmts []Node, label string, innermost bool) bool {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *BreakStatement:
+			if (stmt.Label == "" && innermost) || (stmt.Label != "" && stmt.Label == label) {
+				return true
+			}
+		case *IfStatement:
+			if breaksOut(stmt.Consequence, label, innermost) || breaksOut(stmt.Alternative, label, innermost) {
+				return true
+			}
+		case *WhileStatement:
+			if label != "" && breaksOut(stmt.Body, label, false) {
+				return true
+			}
+		case *ForStatement:
+			if label != "" && breaksOut(stmt.Body, label, false) {
+				return true
+			}
 		}
 	}
-
-	p.nextToken()
-	return stmt, nil
+	return false
 }
 
 const (
 	_ int = iota
 	LOWEST
-	SUM     // + or -
-	PRODUCT // * or /
-	PREFIX  // -X or !X
-	CALL    // myFunction(X)
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	EQUALS      // == or !=
+	LESSGREATER // < <= > >=
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
+	SUM         // + or -
+	PRODUCT     // * / or %
+	CAST        // X as T
+	PREFIX      // -X !X or ~X
+	CALL        // myFunction(X), X.field or X[i]
 )
 
 var precedences = map[lexer.TokenType]int{
-	lexer.PLUS:   SUM,
-	lexer.MINUS:  SUM,
-	lexer.SLASH:  PRODUCT,
-	lexer.STAR:   PRODUCT,
-	lexer.LPAREN: CALL,
+	lexer.OR:        LOGICAL_OR,
+	lexer.AND:       LOGICAL_AND,
+	lexer.EQ:        EQUALS,
+	lexer.NOT_EQ:    EQUALS,
+	lexer.LT:        LESSGREATER,
+	lexer.LT_EQ:     LESSGREATER,
+	lexer.GT:        LESSGREATER,
+	lexer.GT_EQ:     LESSGREATER,
+	lexer.PIPE:      BIT_OR,
+	lexer.CARET:     BIT_XOR,
+	lexer.AMPERSAND: BIT_AND,
+	lexer.SHL:       SHIFT,
+	lexer.SHR:       SHIFT,
+	lexer.PLUS:      SUM,
+	lexer.MINUS:     SUM,
+	lexer.SLASH:     PRODUCT,
+	lexer.STAR:      PRODUCT,
+	lexer.PERCENT:   PRODUCT,
+	lexer.AS:        CAST,
+	lexer.LPAREN:    CALL,
+	lexer.DOT:       CALL,
+	lexer.LBRACKET:  CALL,
 }
 
 func (p *Parser) parseExpression(precedence int, tt Type) (Expression, error) {
 	var leftExp Expression
 
 	switch p.curToken.Type {
-	case lexer.INT:
-		leftExp = p.parseIntegerLiteral()
+	case lexer.INT, lexer.FLOAT:
+		literal, err := p.parseNumericLiteral(tt, false)
+		if err != nil {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    err,
+			}
+		}
+		leftExp = literal
 	case lexer.STRING:
 		leftExp = &StringLiteral{Value: p.curToken.Literal}
-	case lexer.FLOAT:
-		leftExp = p.parseFloatLiteral()
+	case lexer.CHAR:
+		value, _ := utf8.DecodeRuneInString(p.curToken.Literal)
+		leftExp = &CharLiteral{Value: value}
+	case lexer.TRUE, lexer.FALSE:
+		leftExp = &BooleanLiteral{Value: p.curToken.Type == lexer.TRUE}
+	case lexer.RAWTYPE:
+		// conversions are only written with the as operator
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("type %s is not an expression, convert values with as, e.g: x as %s", p.curToken.Literal, p.curToken.Literal),
+		}
 	case lexer.IDENT:
-		varStmt, ok := p.vars[p.curToken.Literal]
-		if ok {
+		varStmt, ok := p.scope.lookup(p.curToken.Literal)
+		switch {
+		case ok && varStmt.Const:
+			leftExp = varStmt.Value
+		case ok:
 			leftExp = &Identifier{Value: varStmt.Name, Type: varStmt.Type}
-		} else {
+		case p.curToken.Literal == "len" && p.peekTokenIs(lexer.LPAREN):
+			expression, err := p.parseLenExpression()
+			if err != nil {
+				return nil, err
+			}
+			leftExp = expression
+		case p.structs[p.curToken.Literal] != nil && p.peekTokenIs(lexer.LBRACE):
+			literal, err := p.parseStructLiteral(p.structs[p.curToken.Literal])
+			if err != nil {
+				return nil, err
+			}
+			leftExp = literal
+		case p.peekTokenIs(lexer.LPAREN):
 			leftExp = &UnboundedIdentifier{Value: p.curToken.Literal}
+		default:
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("%w: %s", ErrVariableUndefined, p.curToken.Literal),
+			}
 		}
 	case lexer.LPAREN:
-		expression, err := p.parseGroupedExpression(tt)
+		expression, err := p.parseGroupedExpression()
+		if err != nil {
+			return nil, err
+		}
+		leftExp = expression
+	case lexer.LBRACKET:
+		expression, err := p.parseArrayLiteral(tt)
+		if err != nil {
+			return nil, err
+		}
+		leftExp = expression
+	case lexer.BANG, lexer.MINUS, lexer.TILDE:
+		expression, err := p.parsePrefixExpression(tt)
 		if err != nil {
 			return nil, err
 		}
 		leftExp = expression
+	case lexer.ILLEGAL:
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("illegal token: %s", p.curToken.Literal),
+		}
 	default:
-		return nil, nil
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("expected expression, got %s", p.curToken.Type.String()),
+		}
 	}
 
 	for !p.peekTokenIs(lexer.SEMICOLON) && precedence < p.peekPrecedence() {
 		switch p.peekToken.Type {
-		case lexer.PLUS, lexer.MINUS, lexer.SLASH, lexer.STAR:
+		case lexer.PLUS, lexer.MINUS, lexer.SLASH, lexer.STAR, lexer.PERCENT,
+			lexer.EQ, lexer.NOT_EQ, lexer.LT, lexer.LT_EQ, lexer.GT, lexer.GT_EQ,
+			lexer.AND, lexer.OR, lexer.AMPERSAND, lexer.PIPE, lexer.CARET,
+			lexer.SHL, lexer.SHR:
 			p.nextToken()
 			exp, err := p.parseInfixExpression(leftExp, tt)
 			if err != nil {
 				return nil, err
 			}
 
+			leftExp = exp
+		case lexer.AS:
+			p.nextToken()
+			exp, err := p.parseCastExpression(leftExp)
+			if err != nil {
+				return nil, err
+			}
+
+			leftExp = exp
+		case lexer.DOT:
+			p.nextToken()
+			exp, err := p.parseFieldAccess(leftExp)
+			if err != nil {
+				return nil, err
+			}
+
+			leftExp = exp
+		case lexer.LBRACKET:
+			p.nextToken()
+			exp, err := p.parseIndexExpression(leftExp)
+			if err != nil {
+				return nil, err
+			}
+
 			leftExp = exp
 		case lexer.LPAREN:
 			p.nextToken()
@@ -589,12 +1574,28 @@ func (p *Parser) parseExpression(precedence int, tt Type) (Expression, error) {
 		}
 	}
 
-	return leftExp, nil
+	if tt == Void {
+		return leftExp, nil
+	}
+
+	converted, err := convertExpression(leftExp, tt)
+	if err != nil {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    err,
+		}
+	}
+
+	return converted, nil
 }
 
-func (p *Parser) parseGroupedExpression(tt Type) (Expression, error) {
+// parseGroupedExpression parses an expression between parentheses, it
+// is verified against tt by the enclosing expression as the group may
+// still be the operand of a cast, e.g: (a + b) as int8
+func (p *Parser) parseGroupedExpression() (Expression, error) {
 	p.nextToken()
-	exp, err := p.parseExpression(LOWEST, tt)
+	exp, err := p.parseExpression(LOWEST, Void)
 	if err != nil {
 		return nil, err
 	}
@@ -671,6 +1672,7 @@ func (p *Parser) parseFnCallExpression(left Expression, tt Type) (Expression, er
 			if err := p.consumeOrFail(lexer.COMMA); err != nil {
 				return nil, err
 			}
+			p.nextToken()
 		}
 
 		return nil, &ErrParser{
@@ -701,6 +1703,7 @@ func (p *Parser) parseFnCallExpression(left Expression, tt Type) (Expression, er
 			if err := p.consumeOrFail(lexer.COMMA); err != nil {
 				return nil, err
 			}
+			p.nextToken()
 		}
 
 		fnStmt.ExpressionsToEvaluate = make([]Expression, len(fnCallExp.Params))
@@ -718,10 +1721,112 @@ func (p *Parser) parseInfixExpression(left Expression, tt Type) (Expression, err
 		Operator: p.curToken.Literal,
 	}
 
+	// the right operand is constrained by the operator instead of
+	// the expected type when the operator changes the result type
+	operandType := tt
+	switch {
+	case comparisonOperators[expression.Operator]:
+		operandType = Void
+	case logicalOperators[expression.Operator]:
+		operandType = Bool
+	case tt == Bool:
+		// arithmetic inside a condition, e.g: a + 1 < b
+		operandType = Void
+	}
+
 	precedence := p.curPrecedence()
 	p.nextToken()
 
-	exp, err := p.parseExpression(precedence, tt)
+	exp, err := p.parseExpression(precedence, operandType)
+	if err != nil {
+		return nil, err
+	}
+
+	expression.Right = exp
+	return expression, nil
+}
+
+// parseCastExpression parses the as operator applied to the value on
+// its left, as! makes a checked cast, e.g: total as! uint8
+func (p *Parser) parseCastExpression(value Expression) (Expression, error) {
+	line, column := p.curToken.Line, p.curToken.Column
+	cast := &CastExpression{}
+
+	if p.peekTokenIs(lexer.BANG) {
+		p.nextToken()
+		cast.Checked = true
+	}
+
+	if !p.expectPeek(lexer.RAWTYPE) {
+		return nil, &ErrParser{
+			Line:   p.peekToken.Line,
+			Column: p.peekToken.Column,
+			Err:    fmt.Errorf("expected type after as, got %s", p.peekToken.Literal),
+		}
+	}
+	cast.Type = getTypeFromLiteral(p.curToken.Literal)
+
+	if err := p.resolveCast(cast, value); err != nil {
+		return nil, &ErrParser{Line: line, Column: column, Err: err}
+	}
+
+	return cast, nil
+}
+
+// resolveCast sets the source type of cast from its value and checks
+// the conversion is allowed.
+func (p *Parser) resolveCast(cast *CastExpression, value Expression) error {
+	var err error
+	cast.From, err = p.inferTypeFromExpression(value)
+	if err != nil {
+		return err
+	}
+
+	// untyped literals are converted to the target integer
+	// directly, so the value is checked against its range
+	if TypeOf(value) == Void && cast.Type.IsInteger() {
+		cast.From = cast.Type
+	}
+
+	cast.Value, err = convertExpression(value, cast.From)
+	if err != nil {
+		return err
+	}
+
+	return p.verifyConversion(cast)
+}
+
+// parsePrefixExpression parses an unary operator applied to the
+// operand that follows it. Negation and complement keep the operand
+// type, the enclosing expression verifies it against tt as the prefix
+// may be the operand of a cast, while ! always expects a bool.
+func (p *Parser) parsePrefixExpression(tt Type) (Expression, error) {
+	expression := &PrefixExpression{
+		Operator: p.curToken.Literal,
+	}
+
+	operandType := Void
+	if expression.Operator == "!" {
+		operandType = Bool
+	}
+
+	p.nextToken()
+
+	// negated numbers are negative literals, so the minimum
+	// value of signed integers is in range, e.g: -128i8
+	if expression.Operator == "-" && (p.curToken.Type == lexer.INT || p.curToken.Type == lexer.FLOAT) {
+		literal, err := p.parseNumericLiteral(tt, true)
+		if err != nil {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    err,
+			}
+		}
+		return literal, nil
+	}
+
+	exp, err := p.parseExpression(PREFIX, operandType)
 	if err != nil {
 		return nil, err
 	}
@@ -730,14 +1835,100 @@ func (p *Parser) parseInfixExpression(left Expression, tt Type) (Expression, err
 	return expression, nil
 }
 
-func (p *Parser) parseIntegerLiteral() *IntegerLiteral {
-	value, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
-	return &IntegerLiteral{Value: value}
+// parseNumericLiteral decodes the current INT or FLOAT token. The literal
+// value must fit its target type: the suffix type if present, otherwise
+// the expected type tt. Integers without both are only checked to fit
+// int64 here, they are checked again once they take a type from their
+// context, int32 by default. Negative literals come from a negation
+// applied to the token.
+func (p *Parser) parseNumericLiteral(tt Type, negative bool) (Expression, error) {
+	literal, suffix := splitNumericSuffix(p.curToken.Literal)
+	literal = strings.ReplaceAll(literal, "_", "")
+
+	sign, written := "", p.curToken.Literal
+	if negative {
+		sign, written = "-", "-"+written
+	}
+
+	var suffixType Type
+	if suffix != "" {
+		var ok bool
+		suffixType, ok = literalSuffixes[suffix]
+		if !ok {
+			return nil, fmt.Errorf("literal suffix %s: type not supported", suffix)
+		}
+	}
+
+	isFloat := p.curToken.Type == lexer.FLOAT || suffixType.IsFloat()
+	if isFloat {
+		target := Float32
+		if suffixType != Void {
+			target = suffixType
+		} else if tt.IsFloat() {
+			target = tt
+		}
+
+		value, err := strconv.ParseFloat(sign+literal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float literal %s: %w", written, err)
+		}
+
+		if target == Float32 && math.Abs(value) > math.MaxFloat32 {
+			return nil, fmt.Errorf("float literal %s overflows %s", written, target)
+		}
+		return &FloatLiteral{Value: value, Type: suffixType}, nil
+	}
+
+	target := Int64
+	if suffixType != Void {
+		target = suffixType
+	} else if tt.IsInteger() {
+		target = tt
+	}
+
+	// base prefixes are handled by base 0, unprefixed literals
+	// are always decimal even with leading zeros
+	base := 10
+	if len(literal) > 2 && literal[0] == '0' && strings.ContainsRune("xXoObB", rune(literal[1])) {
+		base = 0
+	}
+
+	// unsigned values above the int64 range are stored wrapped
+	var value int64
+	var err error
+	switch {
+	case target.IsSigned():
+		value, err = strconv.ParseInt(sign+literal, base, target.bitSize())
+	case negative:
+		err = strconv.ErrRange
+	default:
+		var unsigned uint64
+		unsigned, err = strconv.ParseUint(literal, base, target.bitSize())
+		value = int64(unsigned)
+	}
+
+	if errors.Is(err, strconv.ErrRange) {
+		return nil, fmt.Errorf("integer literal %s overflows %s", written, target)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid integer literal %s: %w", written, err)
+	}
+
+	return &IntegerLiteral{Value: value, Type: suffixType}, nil
 }
 
-func (p *Parser) parseFloatLiteral() *FloatLiteral {
-	value, _ := strconv.ParseFloat(p.curToken.Literal, 64)
-	return &FloatLiteral{Value: value}
+// splitNumericSuffix splits a numeric literal from its type suffix,
+// suffixes start with i, u or f (f is a digit in hex literals).
+func splitNumericSuffix(literal string) (string, string) {
+	isHex := strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X")
+	for idx, ch := range literal {
+		if ch == 'i' || ch == 'u' || (ch == 'f' && !isHex) {
+			return literal[:idx], literal[idx:]
+		}
+	}
+
+	return literal, ""
 }
 
 func (p *Parser) nextToken() {
@@ -766,6 +1957,22 @@ func (p *Parser) consumeOrFail(expected lexer.TokenType) error {
 	return nil
 }
 
+// endStatement checks the statement ends after the current token. The
+// semicolon is consumed while a closing brace or the end of the input
+// is left to the enclosing block.
+func (p *Parser) endStatement() error {
+	if !endOfStatement(p.peekToken.Type) {
+		return &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column + len(p.curToken.Literal),
+			Err:    fmt.Errorf("after %s: expected end of statement or new line", p.curToken.Literal),
+		}
+	}
+
+	p.expectPeek(lexer.SEMICOLON)
+	return nil
+}
+
 func (p *Parser) curPrecedence() int {
 	if p, ok := precedences[p.curToken.Type]; ok {
 		return p
@@ -797,15 +2004,39 @@ func (p *Parser) inferTypeFromExpression(exp Expression) (Type, error) {
 	case *StringLiteral:
 		return String, nil
 	case *IntegerLiteral:
+		if exp.Type != Void {
+			return exp.Type, nil
+		}
 		return Int32, nil
 	case *Identifier:
-		if assignedVar, ok := p.vars[exp.Value]; ok {
+		if assignedVar, ok := p.scope.lookup(exp.Value); ok {
 			return assignedVar.Type, nil
 		}
 
 		return 0, fmt.Errorf("cannot infer type for: %s", exp.Value)
 	case *FloatLiteral:
+		if exp.Type != Void {
+			return exp.Type, nil
+		}
 		return Float32, nil
+	case *CharLiteral:
+		return Char, nil
+	case *BooleanLiteral:
+		return Bool, nil
+	case *CastExpression:
+		return exp.Type, nil
+	case *StructLiteral:
+		return exp.Type, nil
+	case *FieldAccess:
+		return exp.Type, nil
+	case *ArrayLiteral:
+		return exp.Type, nil
+	case *IndexExpression:
+		return exp.Type, nil
+	case *SliceExpression:
+		return exp.Type, nil
+	case *LenExpression:
+		return Int32, nil
 	case *InfixExpression:
 		lhsType, err := p.inferTypeFromExpression(exp.Left)
 		if err != nil {
@@ -817,13 +2048,45 @@ func (p *Parser) inferTypeFromExpression(exp Expression) (Type, error) {
 			return 0, err
 		}
 
+		// integers and floats are never mixed, untyped integer literals
+		// take the type of the other operand, otherwise the narrower
+		// operand is widened
+		switch {
+		case lhsType.IsInteger() && rhsType.IsFloat(), lhsType.IsFloat() && rhsType.IsInteger():
+			return 0, fmt.Errorf("operator %s: mismatched types %s and %s, convert one of the operands", exp.Operator, p.types.Name(lhsType), p.types.Name(rhsType))
+		case TypeOf(exp.Left) == Void && rhsType.IsInteger():
+			lhsType = rhsType
+		case TypeOf(exp.Right) == Void && lhsType.IsInteger():
+			rhsType = lhsType
+		case canWiden(lhsType, rhsType):
+			lhsType = rhsType
+		case canWiden(rhsType, lhsType):
+			rhsType = lhsType
+		}
+
 		if lhsType != rhsType {
 			return 0, errors.New("cannot infer type")
 		}
 
-		return lhsType, nil
+		return p.inferInfixResultType(exp.Operator, lhsType)
 	case *PrefixExpression:
-		return p.inferTypeFromExpression(exp.Right)
+		operandType, err := p.inferTypeFromExpression(exp.Right)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case exp.Operator == "!" && operandType != Bool:
+			return 0, errors.New("operator ! expects a bool operand")
+		case exp.Operator == "-" && !operandType.IsInteger() && !operandType.IsFloat():
+			return 0, errors.New("operator - expects a numeric operand")
+		case exp.Operator == "-" && operandType.IsInteger() && !operandType.IsSigned():
+			return 0, errors.New("operator - expects a signed operand")
+		case exp.Operator == "~" && !operandType.IsInteger():
+			return 0, errors.New("operator ~ expects an integer operand")
+		}
+
+		return operandType, nil
 	case *FnCall:
 		if fn, ok := p.fns[exp.FnName]; ok {
 			return fn.ReturnType, nil
@@ -835,16 +2098,75 @@ func (p *Parser) inferTypeFromExpression(exp Expression) (Type, error) {
 	}
 }
 
+// inferInfixResultType returns the type produced by applying the operator
+// over two operands of type operandType.
+func (p *Parser) inferInfixResultType(operator string, operandType Type) (Type, error) {
+	switch {
+	case operandType.IsStruct() || operandType.isArrayOrSlice():
+		return 0, fmt.Errorf("operator %s does not support %s operands", operator, p.types.Name(operandType))
+	case logicalOperators[operator]:
+		if operandType != Bool {
+			return 0, fmt.Errorf("operator %s expects bool operands", operator)
+		}
+		return Bool, nil
+	case orderingOperators[operator]:
+		if !operandType.IsInteger() && !operandType.IsFloat() && operandType != Char {
+			return 0, fmt.Errorf("operator %s expects numeric operands", operator)
+		}
+		return Bool, nil
+	case comparisonOperators[operator]:
+		return Bool, nil
+	case bitwiseOperators[operator] || operator == "%":
+		if !operandType.IsInteger() {
+			return 0, fmt.Errorf("operator %s expects integer operands", operator)
+		}
+		return operandType, nil
+	case operandType == Bool || operandType == Char:
+		return 0, fmt.Errorf("operator %s does not support %s operands", operator, operandType)
+	default:
+		return operandType, nil
+	}
+}
+
+// endOfStatement reports whether t terminates a statement, new lines
+// reach the parser as semicolons inserted by the lexer and the closing
+// brace of a block ends its last statement.
 func endOfStatement(t lexer.TokenType) bool {
-	return t == lexer.SEMICOLON || t == lexer.NEXTLINE || t == lexer.EOF
+	return t == lexer.SEMICOLON || t == lexer.RBRACE || t == lexer.EOF
 }
 
 func getTypeFromLiteral(literal string) Type {
 	switch literal {
+	case "int8":
+		return Int8
+	case "int16":
+		return Int16
 	case "int32":
 		return Int32
+	case "int64":
+		return Int64
+	case "uint8":
+		return Uint8
+	case "uint16":
+		return Uint16
+	case "uint32":
+		return Uint32
+	case "uint64":
+		return Uint64
+	case "isize":
+		return Isize
+	case "usize":
+		return Usize
+	case "float32":
+		return Float32
+	case "float64":
+		return Float64
 	case "string":
 		return String
+	case "char":
+		return Char
+	case "bool":
+		return Bool
 	default:
 		panic("unreacheable")
 	}
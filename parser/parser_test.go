@@ -2,6 +2,7 @@ package parser_test
 
 import (
 	"errors"
+	"math"
 	"strings"
 	"testing"
 
@@ -11,7 +12,7 @@ import (
 )
 
 func TestParser_ParseProgram(t *testing.T) {
-	input := "var x = 42; var y = 3.14; var z = x + y;"
+	input := "var x = 42; var y = 3.14; var z = x as float32 + y;"
 	l := lexer.NewLexer(strings.NewReader(input))
 	tokens := l.NextToken()
 	p := parser.NewParser(tokens)
@@ -23,31 +24,43 @@ func TestParser_ParseProgram(t *testing.T) {
 	require.Len(t, program.Statements, 3)
 
 	expected := &parser.Program{
+		Types: &parser.Types{},
 		Statements: []parser.Node{
 			&parser.VarStatement{
 				Name: "x",
 				Value: &parser.IntegerLiteral{
 					Value: 42,
+					Type:  parser.Int32,
 				},
+				Type: parser.Int32,
 			},
 			&parser.VarStatement{
 				Name: "y",
 				Value: &parser.FloatLiteral{
 					Value: 3.14,
+					Type:  parser.Float32,
 				},
+				Type: parser.Float32,
 			},
 
 			&parser.VarStatement{
 				Name: "z",
 				Value: &parser.InfixExpression{
-					Left: &parser.Identifier{
-						Value: "x",
+					Left: &parser.CastExpression{
+						Value: &parser.Identifier{
+							Value: "x",
+							Type:  parser.Int32,
+						},
+						From: parser.Int32,
+						Type: parser.Float32,
 					},
 					Operator: "+",
 					Right: &parser.Identifier{
 						Value: "y",
+						Type:  parser.Float32,
 					},
 				},
+				Type: parser.Float32,
 			},
 		},
 	}
@@ -65,21 +78,26 @@ func TestParser_ParsePrecendence(t *testing.T) {
 	require.NoError(t, err)
 
 	expected := &parser.Program{
+		Types: &parser.Types{},
 		Statements: []parser.Node{
 			&parser.VarStatement{
 				Name: "x",
+				Type: parser.Int32,
 				Value: &parser.InfixExpression{
 					Left: &parser.IntegerLiteral{
 						Value: 1,
+						Type:  parser.Int32,
 					},
 					Operator: "+",
 					Right: &parser.InfixExpression{
 						Left: &parser.IntegerLiteral{
 							Value: 2,
+							Type:  parser.Int32,
 						},
 						Operator: "*",
 						Right: &parser.IntegerLiteral{
 							Value: 3,
+							Type:  parser.Int32,
 						},
 					},
 				},
@@ -91,7 +109,7 @@ func TestParser_ParsePrecendence(t *testing.T) {
 }
 
 func TestParser_ParseGroupedExpression(t *testing.T) {
-	input := "var x = 2 * (42 + 3.14);"
+	input := "var x = 2.0 * (42.0 + 3.14);"
 	l := lexer.NewLexer(strings.NewReader(input))
 	tokens := l.NextToken()
 	p := parser.NewParser(tokens)
@@ -100,24 +118,29 @@ func TestParser_ParseGroupedExpression(t *testing.T) {
 	require.NoError(t, err)
 
 	expected := &parser.Program{
+		Types: &parser.Types{},
 		Statements: []parser.Node{
 			&parser.VarStatement{
 				Name: "x",
 				Value: &parser.InfixExpression{
-					Left: &parser.IntegerLiteral{
+					Left: &parser.FloatLiteral{
 						Value: 2,
+						Type:  parser.Float32,
 					},
 					Operator: "*",
 					Right: &parser.InfixExpression{
-						Left: &parser.IntegerLiteral{
+						Left: &parser.FloatLiteral{
 							Value: 42,
+							Type:  parser.Float32,
 						},
 						Operator: "+",
 						Right: &parser.FloatLiteral{
 							Value: 3.14,
+							Type:  parser.Float32,
 						},
 					},
 				},
+				Type: parser.Float32,
 			},
 		},
 	}
@@ -141,6 +164,7 @@ func TestParser_ParseFunction(t *testing.T) {
 	require.Len(t, program.Statements, 1)
 
 	expected := &parser.Program{
+		Types: &parser.Types{},
 		Statements: []parser.Node{
 			&parser.FnStatement{
 				ReturnType: parser.Int32,
@@ -151,13 +175,16 @@ func TestParser_ParseFunction(t *testing.T) {
 				},
 				Body: []parser.Node{
 					&parser.ReturnStatement{
+						Type: parser.Int32,
 						Value: &parser.InfixExpression{
 							Left: &parser.Identifier{
 								Value: "a",
+								Type:  parser.Int32,
 							},
 							Operator: "+",
 							Right: &parser.Identifier{
 								Value: "b",
+								Type:  parser.Int32,
 							},
 						},
 					},
@@ -185,3 +212,1771 @@ func TestParser_ShouldErrorIfReturnIsNotPresent(t *testing.T) {
 		Err:    errors.New("function must have a return"),
 	})
 }
+
+func TestParser_DocComments(t *testing.T) {
+	input := `/// the answer
+var x = 42;
+
+/// returns one
+fn one(): int32 {
+	// a line comment
+	/* a block /* nested */ comment */
+	return 1;
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 2)
+
+	require.Equal(t, "the answer", program.Statements[0].(*parser.VarStatement).Doc)
+	require.Equal(t, "returns one", program.Statements[1].(*parser.FnStatement).Doc)
+	require.Len(t, program.Statements[1].(*parser.FnStatement).Body, 1)
+}
+
+func TestParser_ParseOperatorsPrecedence(t *testing.T) {
+	input := "var x = 1; var y = x + 1 < 3 * x && !(x == 2) || x % 2 != 0;"
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 2)
+
+	x := &parser.Identifier{Value: "x", Type: parser.Int32}
+	expected := &parser.VarStatement{
+		Name: "y",
+		Type: parser.Bool,
+		Value: &parser.InfixExpression{
+			Left: &parser.InfixExpression{
+				Left: &parser.InfixExpression{
+					Left: &parser.InfixExpression{
+						Left:     x,
+						Operator: "+",
+						Right:    &parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+					},
+					Operator: "<",
+					Right: &parser.InfixExpression{
+						Left:     &parser.IntegerLiteral{Value: 3, Type: parser.Int32},
+						Operator: "*",
+						Right:    x,
+					},
+				},
+				Operator: "&&",
+				Right: &parser.PrefixExpression{
+					Operator: "!",
+					Right: &parser.InfixExpression{
+						Left:     x,
+						Operator: "==",
+						Right:    &parser.IntegerLiteral{Value: 2, Type: parser.Int32},
+					},
+				},
+			},
+			Operator: "||",
+			Right: &parser.InfixExpression{
+				Left: &parser.InfixExpression{
+					Left:     x,
+					Operator: "%",
+					Right:    &parser.IntegerLiteral{Value: 2, Type: parser.Int32},
+				},
+				Operator: "!=",
+				Right:    &parser.IntegerLiteral{Value: 0, Type: parser.Int32},
+			},
+		},
+	}
+
+	require.Equal(t, expected, program.Statements[1])
+}
+
+func TestParser_OperatorsTypeChecking(t *testing.T) {
+	cases := map[string]string{
+		"logical_over_int":   "var x = 1 && 2;",
+		"not_over_int":       "var x = 1; var y = !x;",
+		"comparison_as_int":  "var x: int32 = 1 < 2;",
+		"mismatched_compare": "var x = 1; var y = 1 < 2; var z = x == y;",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParser_NumericLiterals(t *testing.T) {
+	input := "var a = 0xFF; var b = 0o17; var c = 0b101; var d = 1_000; var e = 017; var f = 2.5e1; var g = 7i32; var h = 1f32;"
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	expected := []parser.Expression{
+		&parser.IntegerLiteral{Value: 255, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: 15, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: 5, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: 1000, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: 17, Type: parser.Int32},
+		&parser.FloatLiteral{Value: 25, Type: parser.Float32},
+		&parser.IntegerLiteral{Value: 7, Type: parser.Int32},
+		&parser.FloatLiteral{Value: 1, Type: parser.Float32},
+	}
+
+	require.Len(t, program.Statements, len(expected))
+	for idx, stmt := range program.Statements {
+		require.Equal(t, expected[idx], stmt.(*parser.VarStatement).Value)
+	}
+}
+
+func TestParser_NumericLiteralsOutOfRange(t *testing.T) {
+	cases := map[string]string{
+		"int32_overflow":       "var x = 2147483648;",
+		"int32_hex_overflow":   "var x: int32 = 0x1_0000_0000;",
+		"suffix_overflow":      "var x = 9999999999i32;",
+		"float32_overflow":     "var x = 1e39;",
+		"uint8_overflow":       "var x = 256u8;",
+		"negative_unsigned":    "var x: uint32 = -1;",
+		"suffix_type_mismatch": "var x: int32 = 1f32;",
+		"float_int_suffix":     "var x = 2.5i32;",
+		"exponent_int_suffix":  "var x = 1e3u8;",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParser_CharConversions(t *testing.T) {
+	input := "var c = 'a'; var i = c as int32 + 1; var d: char = i as char; var ok = c < d;"
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 4)
+
+	require.Equal(t, &parser.VarStatement{
+		Name:  "c",
+		Value: &parser.CharLiteral{Value: 'a'},
+		Type:  parser.Char,
+	}, program.Statements[0])
+
+	require.Equal(t, &parser.VarStatement{
+		Name: "d",
+		Value: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "i", Type: parser.Int32},
+			From:  parser.Int32,
+			Type:  parser.Char,
+		},
+		Type: parser.Char,
+	}, program.Statements[2])
+
+	require.Equal(t, parser.Bool, program.Statements[3].(*parser.VarStatement).Type)
+}
+
+func TestParser_InvalidCharUsage(t *testing.T) {
+	cases := map[string]string{
+		"char_arithmetic":      "var c = 'a' + 'b';",
+		"implicit_int_to_char": "var c: char = 65;",
+		"implicit_char_to_int": "var i: int32 = 'a';",
+		"invalid_scalar":       "var c = 55296 as char;",
+		"string_to_char":       `var c = "a" as char;`,
+		"char_compared_to_int": "var b = 'a' == 97;",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParser_SemicolonInsertion(t *testing.T) {
+	input := `fn sum(a: int32, b: int32,
+	c: int32): int32 {
+	var d = 1
+	return d +
+		2 +
+		3
+}
+
+fn main(): int32 {
+	var total = sum(1,
+		2, 3)
+	total = total *
+		2
+	return total }`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 2)
+
+	sum := program.Statements[0].(*parser.FnStatement)
+	require.Len(t, sum.Args, 3)
+	require.Len(t, sum.Body, 2)
+
+	main := program.Statements[1].(*parser.FnStatement)
+	require.Len(t, main.Body, 3)
+	require.Len(t, main.Body[0].(*parser.VarStatement).Value.(*parser.FnCall).Params, 3)
+}
+
+func TestParser_StatementsMustBeTerminated(t *testing.T) {
+	input := `var x = 1 var y = 2`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	_, err := p.ParseProgram()
+	require.ErrorContains(t, err, "expected end of statement")
+}
+
+func TestParser_IfStatements(t *testing.T) {
+	input := `fn check(): int32 {
+	var x = 1
+	if x > 1 {
+		return 2
+	} else if x == 1 {
+		x = 3
+	} else {
+		return 4
+	}
+	return x
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	fn := program.Statements[0].(*parser.FnStatement)
+	require.Len(t, fn.Body, 3)
+
+	ifStmt := fn.Body[1].(*parser.IfStatement)
+	require.Equal(t, ">", ifStmt.Condition.(*parser.InfixExpression).Operator)
+	require.Len(t, ifStmt.Consequence, 1)
+	require.Len(t, ifStmt.Alternative, 1)
+
+	elseIf := ifStmt.Alternative[0].(*parser.IfStatement)
+	require.Len(t, elseIf.Consequence, 1)
+	require.IsType(t, &parser.ReturnStatement{}, elseIf.Alternative[0])
+}
+
+func TestParser_IfStatementErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"condition is not a bool": {
+			input:    "fn main() {\n\tif 1 {\n\t}\n}",
+			expected: "wrong type assignment",
+		},
+		"missing return without else": {
+			input:    "fn main(): int32 {\n\tvar x = 1\n\tif x > 0 {\n\t\treturn 1\n\t}\n}",
+			expected: "function must have a return",
+		},
+		"missing return in a branch": {
+			input:    "fn main(): int32 {\n\tvar x = 1\n\tif x > 0 {\n\t\treturn 1\n\t} else {\n\t\tx = 2\n\t}\n}",
+			expected: "function must have a return",
+		},
+		"else without block": {
+			input:    "fn main() {\n\tvar x = 1\n\tif x > 0 {\n\t} else x = 2\n}",
+			expected: "expected: if or {",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_Loops(t *testing.T) {
+	input := `fn main() {
+	var i = 0
+	outer: while i < 10 {
+		loop {
+			break outer
+		}
+		continue
+	}
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	fn := program.Statements[0].(*parser.FnStatement)
+	while := fn.Body[1].(*parser.WhileStatement)
+	require.Equal(t, "outer", while.Label)
+	require.NotNil(t, while.Condition)
+	require.Len(t, while.Body, 2)
+
+	inner := while.Body[0].(*parser.WhileStatement)
+	require.Nil(t, inner.Condition)
+	require.Equal(t, &parser.BreakStatement{Label: "outer"}, inner.Body[0])
+	require.Equal(t, &parser.ContinueStatement{}, while.Body[1])
+}
+
+func TestParser_LoopErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"break outside of a loop": {
+			input:    "fn main() {\n\tbreak\n}",
+			expected: "break outside of a loop",
+		},
+		"continue outside of a loop": {
+			input:    "fn main() {\n\tvar x = 1\n\tif x > 0 {\n\t\tcontinue\n\t}\n}",
+			expected: "continue outside of a loop",
+		},
+		"undefined label": {
+			input:    "fn main() {\n\tloop {\n\t\tbreak outer\n\t}\n}",
+			expected: "undefined loop label: outer",
+		},
+		"label without loop": {
+			input:    "fn main() {\n\touter: var x = 1\n}",
+			expected: "expected loop after label outer",
+		},
+		"condition is not a bool": {
+			input:    "fn main() {\n\twhile 1 {\n\t}\n}",
+			expected: "wrong type assignment",
+		},
+		"loop with break does not return": {
+			input:    "fn main(): int32 {\n\tloop {\n\t\tbreak\n\t}\n}",
+			expected: "function must have a return",
+		},
+		"if at top level": {
+			input:    "if true {\n}",
+			expected: "if statements can only be used inside functions",
+		},
+		"while at top level": {
+			input:    "fn main() {\n}\nwhile true {\n}",
+			expected: "while statements can only be used inside functions",
+		},
+		"for at top level": {
+			input:    "for i in 0..3 {\n}",
+			expected: "for statements can only be used inside functions",
+		},
+		"labeled loop at top level": {
+			input:    "outer: loop {\n\tbreak outer\n}",
+			expected: "labeled statements can only be used inside functions",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_ForStatements(t *testing.T) {
+	input := `fn main() {
+	var n = 10
+	for i in 0..n {
+		n = n + i
+	}
+	rows: for j in 1..=n step 2 {
+		break rows
+	}
+	var big: int64 = 5000000000
+	for k in 0..big {
+	}
+	var count: uint32 = 3
+	for l in 0..=count step 2 {
+	}
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	fn := program.Statements[0].(*parser.FnStatement)
+
+	exclusive := fn.Body[1].(*parser.ForStatement)
+	require.Equal(t, "i", exclusive.Variable.Name)
+	require.Equal(t, parser.Int32, exclusive.Variable.Type)
+	require.False(t, exclusive.Inclusive)
+	require.Equal(t, int64(1), exclusive.Step)
+	require.Len(t, exclusive.Body, 1)
+
+	inclusive := fn.Body[2].(*parser.ForStatement)
+	require.Equal(t, "rows", inclusive.Label)
+	require.True(t, inclusive.Inclusive)
+	require.Equal(t, int64(2), inclusive.Step)
+
+	// the variable takes the type of the bounds
+	wide := fn.Body[4].(*parser.ForStatement)
+	require.Equal(t, parser.Int64, wide.Variable.Type)
+	require.Equal(t, &parser.IntegerLiteral{Value: 0, Type: parser.Int64}, wide.Start)
+
+	unsigned := fn.Body[6].(*parser.ForStatement)
+	require.Equal(t, parser.Uint32, unsigned.Variable.Type)
+}
+
+func TestParser_ForStatementErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"missing range operator": {
+			input:    "fn main() {\n\tfor i in 10 {\n\t}\n}",
+			expected: "expected: .. or ..=",
+		},
+		"zero step": {
+			input:    "fn main() {\n\tfor i in 0..10 step 0 {\n\t}\n}",
+			expected: "range step must be a positive integer",
+		},
+		"non integer bounds": {
+			input:    "fn main() {\n\tfor i in 0..\"ten\" {\n\t}\n}",
+			expected: "range bounds: mismatched or not integer types void and string",
+		},
+		"mismatched bounds": {
+			input:    "fn main() {\n\tvar a: int32 = 0\n\tvar b: uint32 = 10\n\tfor i in a..b {\n\t}\n}",
+			expected: "range bounds: mismatched or not integer types int32 and uint32",
+		},
+		"bound overflows the variable": {
+			input:    "fn main() {\n\tfor i in 0..5000000000 {\n\t}\n}",
+			expected: "integer literal 5000000000 overflows int32",
+		},
+		"variable scoped to the body": {
+			input:    "fn main() {\n\tfor i in 0..10 {\n\t}\n\ti = 1\n}",
+			expected: "variable undefined: i",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_Booleans(t *testing.T) {
+	input := `var ok: bool = true
+var done = false
+var both = ok && !done
+var same = ok == done`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 4)
+
+	for _, stmt := range program.Statements {
+		require.Equal(t, parser.Bool, stmt.(*parser.VarStatement).Type)
+	}
+
+	require.Equal(t, &parser.BooleanLiteral{Value: true}, program.Statements[0].(*parser.VarStatement).Value)
+	require.Equal(t, &parser.BooleanLiteral{Value: false}, program.Statements[1].(*parser.VarStatement).Value)
+}
+
+func TestParser_BooleanTypeErrors(t *testing.T) {
+	cases := map[string]string{
+		"int assigned to bool":   "var ok: bool = 1",
+		"bool assigned to int":   "var n: int32 = true",
+		"ordering booleans":      "var ok = true < false",
+		"arithmetic on booleans": "var ok = true + false",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParser_PrefixExpressions(t *testing.T) {
+	input := `var a = -1
+var b: int32 = -a * 2
+var c = ~b
+var d = !(a < b)
+var e = -1.5
+var f = -a < b`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 6)
+
+	expectedTypes := []parser.Type{parser.Int32, parser.Int32, parser.Int32, parser.Bool, parser.Float32, parser.Bool}
+	for idx, stmt := range program.Statements {
+		require.Equal(t, expectedTypes[idx], stmt.(*parser.VarStatement).Type)
+	}
+
+	// prefix operators bind tighter than any infix operator
+	require.Equal(t, &parser.InfixExpression{
+		Left: &parser.PrefixExpression{
+			Operator: "-",
+			Right:    &parser.Identifier{Value: "a", Type: parser.Int32},
+		},
+		Operator: "*",
+		Right:    &parser.IntegerLiteral{Value: 2, Type: parser.Int32},
+	}, program.Statements[1].(*parser.VarStatement).Value)
+}
+
+func TestParser_PrefixExpressionErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"not on integer": {
+			input:    "var a = 1\nvar b = !a",
+			expected: "wrong type assignment",
+		},
+		"negated bool": {
+			input:    "var a = true\nvar b = -a",
+			expected: "operator - expects a numeric operand",
+		},
+		"complement of float": {
+			input:    "var a = ~1.5",
+			expected: "operator ~ expects an integer operand",
+		},
+		"negated string": {
+			input:    `var a = -"text"`,
+			expected: "operator - expects a numeric operand",
+		},
+		"not assigned to int": {
+			input:    "var a: int32 = !true",
+			expected: "int32 allowed prefix operators: - ~",
+		},
+		"complement assigned to bool": {
+			input:    "var a: bool = ~1",
+			expected: "bool allowed prefix operators: !",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_CompoundAssignments(t *testing.T) {
+	input := `var x = 10
+x += 2
+x *= x - 1
+x %= 7
+x++
+x--`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 6)
+
+	x := &parser.Identifier{Value: "x", Type: parser.Int32}
+
+	// the whole value is the right operand
+	require.Equal(t, &parser.ReassignVarStatement{
+		VarName: "x",
+		Type:    parser.Int32,
+		Value: &parser.InfixExpression{
+			Left:     x,
+			Operator: "*",
+			Right: &parser.InfixExpression{
+				Left:     x,
+				Operator: "-",
+				Right:    &parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+			},
+		},
+	}, program.Statements[2])
+
+	expectedOperators := []string{"+", "*", "%", "+", "-"}
+	for idx, stmt := range program.Statements[1:] {
+		reassign := stmt.(*parser.ReassignVarStatement)
+		require.Equal(t, expectedOperators[idx], reassign.Value.(*parser.InfixExpression).Operator)
+	}
+
+	require.Equal(t, &parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+		program.Statements[5].(*parser.ReassignVarStatement).Value.(*parser.InfixExpression).Right)
+}
+
+func TestParser_CompoundAssignmentErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"increment bool": {
+			input:    "var ok = true\nok++",
+			expected: "operator ++: bool variables are not numeric",
+		},
+		"add assign string": {
+			input:    "var s = \"a\"\ns += \"b\"",
+			expected: "operator +=: string variables are not numeric",
+		},
+		"remainder of float": {
+			input:    "var f = 1.5\nf %= 2.0",
+			expected: "operator %=: float32 variables are not integers",
+		},
+		"mismatched value": {
+			input:    "var x = 1\nx -= true",
+			expected: "wrong type assignment",
+		},
+		"undefined variable": {
+			input:    "y += 1",
+			expected: "variable undefined: y",
+		},
+		"missing terminator": {
+			input:    "var x = 1\nx++ x",
+			expected: "after ++: expected end of statement",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_Constants(t *testing.T) {
+	input := `const SIZE: int32 = 16
+const MASK = SIZE * 2 - 1 | 1 << 8
+const LETTER = (65 + 1) as char
+const DEBUG: bool = !(SIZE > 10)
+
+fn main(): int32 {
+	const LIMIT = SIZE + MASK
+	var total = LIMIT
+	return total
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 5)
+
+	expected := []*parser.VarStatement{
+		{Name: "SIZE", Type: parser.Int32, Value: &parser.IntegerLiteral{Value: 16, Type: parser.Int32}, Const: true},
+		{Name: "MASK", Type: parser.Int32, Value: &parser.IntegerLiteral{Value: 287, Type: parser.Int32}, Const: true},
+		{Name: "LETTER", Type: parser.Char, Value: &parser.CharLiteral{Value: 'B'}, Const: true},
+		{Name: "DEBUG", Type: parser.Bool, Value: &parser.BooleanLiteral{Value: false}, Const: true},
+	}
+
+	for idx, stmt := range expected {
+		require.Equal(t, stmt, program.Statements[idx])
+	}
+
+	// uses of a constant are replaced by its value
+	main := program.Statements[4].(*parser.FnStatement)
+	require.Equal(t, &parser.IntegerLiteral{Value: 303, Type: parser.Int32}, main.Body[0].(*parser.VarStatement).Value)
+	require.Equal(t, &parser.IntegerLiteral{Value: 303, Type: parser.Int32}, main.Body[1].(*parser.VarStatement).Value)
+	require.Equal(t, parser.Int32, main.Body[1].(*parser.VarStatement).Type)
+}
+
+func TestParser_Int64Constants(t *testing.T) {
+	input := `const MAX: int64 = 9223372036854775806 + 1
+const MIN: int64 = -9223372036854775807 - 1
+const HALF = MIN / 2 * 2
+const REMAINDER = MIN % -1
+const TOP = 1i64 << 62`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	expected := []int64{math.MaxInt64, math.MinInt64, math.MinInt64, 0, 1 << 62}
+	for idx, value := range expected {
+		require.Equal(t, &parser.IntegerLiteral{Value: value, Type: parser.Int64}, program.Statements[idx].(*parser.VarStatement).Value)
+	}
+}
+
+func TestParser_ConstantErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"reassigned": {
+			input:    "const A = 1\nA = 2",
+			expected: "cannot assign to constant A",
+		},
+		"incremented": {
+			input:    "const A = 1\nA++",
+			expected: "cannot assign to constant A",
+		},
+		"runtime value": {
+			input:    "var a = 1\nconst B = a + 1",
+			expected: "constant B: not a constant expression: a is a variable",
+		},
+		"function call": {
+			input:    "fn one(): int32 {\n\treturn 1\n}\nconst B = one()",
+			expected: "not a constant expression: call to one",
+		},
+		"overflow": {
+			input:    "const A = 2147483647\nconst B = A + 1",
+			expected: "constant B: 2147483648 overflows int32",
+		},
+		"int64 addition overflow": {
+			input:    "const A: int64 = 9223372036854775807 + 1",
+			expected: "constant A: 9223372036854775807 + 1 overflows int64",
+		},
+		"int64 subtraction overflow": {
+			input:    "const A: int64 = -9223372036854775807 - 2",
+			expected: "constant A: -9223372036854775807 - 2 overflows int64",
+		},
+		"int64 multiplication overflow": {
+			input:    "const A: int64 = 4611686018427387904 * 4",
+			expected: "constant A: 4611686018427387904 * 4 overflows int64",
+		},
+		"int64 division overflow": {
+			input:    "const MIN: int64 = -9223372036854775807 - 1\nconst A = MIN / -1",
+			expected: "constant A: -9223372036854775808 / -1 overflows int64",
+		},
+		"int64 negation overflow": {
+			input:    "const MIN: int64 = -9223372036854775807 - 1\nconst A = -MIN",
+			expected: "constant A: -(-9223372036854775808) overflows int64",
+		},
+		"int64 shift overflow": {
+			input:    "const A: int64 = 1 << 63",
+			expected: "constant A: 1 << 63 overflows int64",
+		},
+		"division by zero": {
+			input:    "const A = 0\nconst B = 1 / A",
+			expected: "constant B: division by zero",
+		},
+		"uninitialized": {
+			input:    "const A: int32",
+			expected: "constant A must be initialized",
+		},
+		"mismatched type": {
+			input:    "const A: bool = 1",
+			expected: "wrong type assignment",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_IntegerTypes(t *testing.T) {
+	input := `var small: int8 = -128
+var big = 5000000000i64
+var count: uint32 = 4000000000
+var wide: int64 = small + 1
+var bytes = 255u8
+var total = count + bytes
+var ok = big > small
+var size: usize = 8
+var narrow = big as int8`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 9)
+
+	expectedTypes := []parser.Type{
+		parser.Int8, parser.Int64, parser.Uint32, parser.Int64, parser.Uint8,
+		parser.Uint32, parser.Bool, parser.Usize, parser.Int8,
+	}
+	for idx, stmt := range program.Statements {
+		require.Equal(t, expectedTypes[idx], stmt.(*parser.VarStatement).Type)
+	}
+
+	// untyped literals take the expected type
+	require.Equal(t, &parser.IntegerLiteral{Value: 4000000000, Type: parser.Uint32},
+		program.Statements[2].(*parser.VarStatement).Value)
+
+	// narrower operands are widened before the operation
+	require.Equal(t, &parser.InfixExpression{
+		Left: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "small", Type: parser.Int8},
+			From:  parser.Int8,
+			Type:  parser.Int64,
+		},
+		Operator: "+",
+		Right:    &parser.IntegerLiteral{Value: 1, Type: parser.Int64},
+	}, program.Statements[3].(*parser.VarStatement).Value)
+
+	require.Equal(t, &parser.InfixExpression{
+		Left:     &parser.Identifier{Value: "count", Type: parser.Uint32},
+		Operator: "+",
+		Right: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "bytes", Type: parser.Uint8},
+			From:  parser.Uint8,
+			Type:  parser.Uint32,
+		},
+	}, program.Statements[5].(*parser.VarStatement).Value)
+
+	// comparisons widen both operands to the wider type
+	require.Equal(t, &parser.InfixExpression{
+		Left:     &parser.Identifier{Value: "big", Type: parser.Int64},
+		Operator: ">",
+		Right: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "small", Type: parser.Int8},
+			From:  parser.Int8,
+			Type:  parser.Int64,
+		},
+	}, program.Statements[6].(*parser.VarStatement).Value)
+}
+
+func TestParser_IntegerTypeErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"implicit narrowing": {
+			input:    "var a: int64 = 1\nvar b: int32 = a",
+			expected: "wrong type assignment",
+		},
+		"signed to unsigned": {
+			input:    "var a: int8 = 1\nvar b: uint64 = a",
+			expected: "wrong type assignment",
+		},
+		"unsigned to signed of same size": {
+			input:    "var a: uint32 = 1\nvar b: int32 = a",
+			expected: "wrong type assignment",
+		},
+		"mixed signedness": {
+			input:    "var a: int32 = 1\nvar b: uint32 = 2\nvar c = a + b",
+			expected: "cannot infer type",
+		},
+		"literal overflow": {
+			input:    "var a: uint8 = 256",
+			expected: "integer literal 256 overflows uint8",
+		},
+		"inferred literal overflow": {
+			input:    "var a: int16 = 1\nvar b = a + 40000",
+			expected: "integer literal 40000 overflows int16",
+		},
+		"negated unsigned": {
+			input:    "var a: uint16 = 1\nvar b: uint16 = -a",
+			expected: "uint16 values cannot be negated",
+		},
+		"pointer sized is not wider than 32 bits": {
+			input:    "var a: uint32 = 1\nvar b: isize = a",
+			expected: "wrong type assignment",
+		},
+		"constant overflow": {
+			input:    "const A: uint8 = 200\nconst B = A + 100",
+			expected: "200 + 100 overflows uint8",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_FloatTypes(t *testing.T) {
+	input := `var ratio = 0.5
+var precise: float64 = 1e300
+var sum = precise + ratio
+var scaled: float64 = ratio * 2.0
+var less = ratio < 1.5
+var count = 3
+var average = count as float64 / precise
+var whole = average as int32
+var half = 1.5f64 / 3f64`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 9)
+
+	expectedTypes := []parser.Type{
+		parser.Float32, parser.Float64, parser.Float64, parser.Float64, parser.Bool,
+		parser.Int32, parser.Float64, parser.Int32, parser.Float64,
+	}
+	for idx, stmt := range program.Statements {
+		require.Equal(t, expectedTypes[idx], stmt.(*parser.VarStatement).Type)
+	}
+
+	// float32 values are widened to float64
+	require.Equal(t, &parser.InfixExpression{
+		Left:     &parser.Identifier{Value: "precise", Type: parser.Float64},
+		Operator: "+",
+		Right: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "ratio", Type: parser.Float32},
+			From:  parser.Float32,
+			Type:  parser.Float64,
+		},
+	}, program.Statements[2].(*parser.VarStatement).Value)
+
+	// untyped literals take the expected type
+	require.Equal(t, &parser.InfixExpression{
+		Left: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "ratio", Type: parser.Float32},
+			From:  parser.Float32,
+			Type:  parser.Float64,
+		},
+		Operator: "*",
+		Right:    &parser.FloatLiteral{Value: 2, Type: parser.Float64},
+	}, program.Statements[3].(*parser.VarStatement).Value)
+
+	require.Equal(t, &parser.InfixExpression{
+		Left:     &parser.Identifier{Value: "ratio", Type: parser.Float32},
+		Operator: "<",
+		Right:    &parser.FloatLiteral{Value: 1.5, Type: parser.Float32},
+	}, program.Statements[4].(*parser.VarStatement).Value)
+}
+
+func TestParser_FloatTypeErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"mixed arithmetic": {
+			input:    "var a = 1\nvar b = 2.5\nvar c = a + b",
+			expected: "operator +: mismatched types int32 and float32, convert one of the operands",
+		},
+		"mixed literal arithmetic": {
+			input:    "var a = 2.5\nvar b = a * 2",
+			expected: "operator *: mismatched types float32 and int32",
+		},
+		"integer assigned to float": {
+			input:    "var a = 1\nvar b: float64 = a",
+			expected: "wrong type assignment",
+		},
+		"implicit narrowing": {
+			input:    "var a: float64 = 1.0\nvar b: float32 = a",
+			expected: "wrong type assignment",
+		},
+		"float assigned to integer": {
+			input:    "var a: int64 = 1.5",
+			expected: "wrong type assignment",
+		},
+		"float remainder": {
+			input:    "var a: float32 = 1.5 % 2.0",
+			expected: "float32 allowed infix operators: + - * /",
+		},
+		"float32 literal overflow": {
+			input:    "var a: float32 = 1e39",
+			expected: "float literal 1e39 overflows float32",
+		},
+		"constant conversion overflow": {
+			input:    "const A: float64 = 1e300\nconst B = A as float32",
+			expected: "+Inf overflows float32",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_CastExpressions(t *testing.T) {
+	input := `var small: int8 = -3
+var count: uint32 = 7
+var total = count as int64 * 2 + small as int64
+var byte = (count + 1) as! uint8
+var negated = -small as uint8
+var ratio = count as float64
+var whole = ratio as! int32`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 7)
+
+	expectedTypes := []parser.Type{
+		parser.Int8, parser.Uint32, parser.Int64, parser.Uint8, parser.Uint8, parser.Float64, parser.Int32,
+	}
+	for idx, stmt := range program.Statements {
+		require.Equal(t, expectedTypes[idx], stmt.(*parser.VarStatement).Type)
+	}
+
+	// as binds tighter than arithmetic operators
+	require.Equal(t, &parser.InfixExpression{
+		Left: &parser.InfixExpression{
+			Left: &parser.CastExpression{
+				Value: &parser.Identifier{Value: "count", Type: parser.Uint32},
+				From:  parser.Uint32,
+				Type:  parser.Int64,
+			},
+			Operator: "*",
+			Right:    &parser.IntegerLiteral{Value: 2, Type: parser.Int64},
+		},
+		Operator: "+",
+		Right: &parser.CastExpression{
+			Value: &parser.Identifier{Value: "small", Type: parser.Int8},
+			From:  parser.Int8,
+			Type:  parser.Int64,
+		},
+	}, program.Statements[2].(*parser.VarStatement).Value)
+
+	require.Equal(t, &parser.CastExpression{
+		Value: &parser.InfixExpression{
+			Left:     &parser.Identifier{Value: "count", Type: parser.Uint32},
+			Operator: "+",
+			Right:    &parser.IntegerLiteral{Value: 1, Type: parser.Uint32},
+		},
+		From:    parser.Uint32,
+		Type:    parser.Uint8,
+		Checked: true,
+	}, program.Statements[3].(*parser.VarStatement).Value)
+
+	// and looser than prefix operators
+	require.Equal(t, &parser.CastExpression{
+		Value: &parser.PrefixExpression{
+			Operator: "-",
+			Right:    &parser.Identifier{Value: "small", Type: parser.Int8},
+		},
+		From: parser.Int8,
+		Type: parser.Uint8,
+	}, program.Statements[4].(*parser.VarStatement).Value)
+}
+
+func TestParser_CastExpressionErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"missing type": {
+			input:    "var a = 1\nvar b = a as 2",
+			expected: "expected type after as, got 2",
+		},
+		"not convertible": {
+			input:    "var a = true\nvar b = a as int32",
+			expected: "cannot convert bool to int32",
+		},
+		"checked char": {
+			input:    "var a = 'a'\nvar b = a as! int32",
+			expected: "cannot check conversion of char to int32, only numbers are checked",
+		},
+		"cast result is verified": {
+			input:    "var a: int64 = 1\nvar b: int32 = a as int16 + a",
+			expected: "wrong type assignment",
+		},
+		"literal out of range": {
+			input:    "var a = 300 as uint8",
+			expected: "integer literal 300 overflows uint8",
+		},
+		"checked constant narrowing": {
+			input:    "const A = 300\nconst B = A as! int8",
+			expected: "checked conversion of 300 to int8 loses information",
+		},
+		"checked constant sign": {
+			input:    "const A: int32 = -1\nconst B = A as! uint32",
+			expected: "checked conversion of -1 to uint32 loses information",
+		},
+		"checked constant fraction": {
+			input:    "const A = 2.5 as! int32",
+			expected: "checked conversion of 2.5 to int32 loses information",
+		},
+		"conversion call": {
+			input:    "var a = 1\nvar b = int64(a)",
+			expected: "type int64 is not an expression, convert values with as, e.g: x as int64",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_ConstantCasts(t *testing.T) {
+	input := `const A: int32 = 300
+const B = A as int8
+const C = 1e10 as int32
+const D = -7.9 as int32
+const E = 100 as! uint8
+const F: uint8 = 200
+const G = F as! int64`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+
+	values := make([]parser.Expression, 0, len(program.Statements))
+	for _, stmt := range program.Statements {
+		values = append(values, stmt.(*parser.VarStatement).Value)
+	}
+
+	require.Equal(t, []parser.Expression{
+		&parser.IntegerLiteral{Value: 300, Type: parser.Int32},
+		// integers wrap, floats truncate toward zero and saturate
+		&parser.IntegerLiteral{Value: 44, Type: parser.Int8},
+		&parser.IntegerLiteral{Value: math.MaxInt32, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: -7, Type: parser.Int32},
+		&parser.IntegerLiteral{Value: 100, Type: parser.Uint8},
+		&parser.IntegerLiteral{Value: 200, Type: parser.Uint8},
+		&parser.IntegerLiteral{Value: 200, Type: parser.Int64},
+	}, values)
+}
+
+func TestParser_Structs(t *testing.T) {
+	input := `struct Point {
+	x: int32,
+	y: int32
+}
+
+struct Segment { from: Point, to: Point, visible: bool }
+
+fn length(s: Segment): int32 {
+	return s.to.x - s.from.x
+}
+
+fn main(): int32 {
+	var p = Point { y: 2, x: 1 }
+	p.x = 3
+	p.y += 1
+	var s: Segment = Segment {
+		from: p,
+		to: Point { x: 10, y: 0 },
+		visible: true,
+	}
+	s.to.y = p.x
+	return length(s)
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 4)
+
+	point := program.Statements[0].(*parser.StructStatement)
+	require.Equal(t, "Point", point.Name)
+	require.Equal(t, []*parser.Field{{Name: "x", Type: parser.Int32}, {Name: "y", Type: parser.Int32}}, point.Fields)
+	require.True(t, point.Type.IsStruct())
+	require.Equal(t, "Point", program.Types.Name(point.Type))
+	require.Same(t, point, program.Types.Struct(point.Type))
+
+	segment := program.Statements[1].(*parser.StructStatement)
+	require.Equal(t, []*parser.Field{
+		{Name: "from", Type: point.Type},
+		{Name: "to", Type: point.Type},
+		{Name: "visible", Type: parser.Bool},
+	}, segment.Fields)
+
+	length := program.Statements[2].(*parser.FnStatement)
+	require.Equal(t, segment.Type, length.Args[0].Type)
+
+	// fields are read through nested accesses
+	segmentArg := &parser.Identifier{Value: "s", Type: segment.Type}
+	require.Equal(t, &parser.InfixExpression{
+		Left: &parser.FieldAccess{
+			Value: &parser.FieldAccess{Value: segmentArg, Field: "to", Index: 1, Type: point.Type},
+			Field: "x",
+			Index: 0,
+			Type:  parser.Int32,
+		},
+		Operator: "-",
+		Right: &parser.FieldAccess{
+			Value: &parser.FieldAccess{Value: segmentArg, Field: "from", Index: 0, Type: point.Type},
+			Field: "x",
+			Index: 0,
+			Type:  parser.Int32,
+		},
+	}, length.Body[0].(*parser.ReturnStatement).Value)
+
+	body := program.Statements[3].(*parser.FnStatement).Body
+
+	// literal fields are stored in declaration order
+	require.Equal(t, &parser.VarStatement{
+		Name: "p",
+		Type: point.Type,
+		Value: &parser.StructLiteral{
+			Type: point.Type,
+			Fields: []parser.Expression{
+				&parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+				&parser.IntegerLiteral{Value: 2, Type: parser.Int32},
+			},
+		},
+	}, body[0])
+
+	pointVar := &parser.Identifier{Value: "p", Type: point.Type}
+	require.Equal(t, &parser.AssignFieldStatement{
+		Target: &parser.FieldAccess{Value: pointVar, Field: "x", Index: 0, Type: parser.Int32},
+		Value:  &parser.IntegerLiteral{Value: 3, Type: parser.Int32},
+	}, body[1])
+
+	pointY := &parser.FieldAccess{Value: pointVar, Field: "y", Index: 1, Type: parser.Int32}
+	require.Equal(t, &parser.AssignFieldStatement{
+		Target: pointY,
+		Value: &parser.InfixExpression{
+			Left:     pointY,
+			Operator: "+",
+			Right:    &parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+		},
+	}, body[2])
+
+	require.Equal(t, &parser.AssignFieldStatement{
+		Target: &parser.FieldAccess{
+			Value: &parser.FieldAccess{
+				Value: &parser.Identifier{Value: "s", Type: segment.Type},
+				Field: "to",
+				Index: 1,
+				Type:  point.Type,
+			},
+			Field: "y",
+			Index: 1,
+			Type:  parser.Int32,
+		},
+		Value: &parser.FieldAccess{Value: pointVar, Field: "x", Index: 0, Type: parser.Int32},
+	}, body[4])
+}
+
+func TestParser_TypesArePerProgram(t *testing.T) {
+	parse := func(input string) *parser.Program {
+		l := lexer.NewLexer(strings.NewReader(input))
+		p := parser.NewParser(l.NextToken())
+
+		program, err := p.ParseProgram()
+		require.NoError(t, err)
+		return program
+	}
+
+	first := parse("struct Point { x: int32, y: int32 }\nvar points: [2]Point")
+	second := parse("struct Size { width: int64 }\nvar sizes: []Size")
+
+	// each program numbers its own structs and arrays from the same Type
+	point := first.Statements[0].(*parser.StructStatement)
+	size := second.Statements[0].(*parser.StructStatement)
+	require.Equal(t, point.Type, size.Type)
+	require.Equal(t, "Point", first.Types.Name(point.Type))
+	require.Equal(t, "Size", second.Types.Name(size.Type))
+
+	points := first.Statements[1].(*parser.VarStatement)
+	sizes := second.Statements[1].(*parser.VarStatement)
+	require.Equal(t, points.Type, sizes.Type)
+	require.Equal(t, "[2]Point", first.Types.Name(points.Type))
+	require.Equal(t, "[]Size", second.Types.Name(sizes.Type))
+}
+
+func TestParser_StructErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"duplicate struct": {
+			input:    "struct A { x: int32 }\nstruct A { y: int32 }",
+			expected: "struct A already declared",
+		},
+		"duplicate field": {
+			input:    "struct A { x: int32, x: bool }",
+			expected: "duplicate field x in struct A",
+		},
+		"recursive struct": {
+			input:    "struct A { next: A }",
+			expected: "struct A cannot contain itself",
+		},
+		"unknown field type": {
+			input:    "struct A { b: B }",
+			expected: "unknown type B",
+		},
+		"nested declaration": {
+			input:    "fn main() {\nstruct A { x: int32 }\n}",
+			expected: "structs can only be declared at the top level",
+		},
+		"missing field": {
+			input:    "struct A { x: int32, y: int32 }\nvar a = A { x: 1 }",
+			expected: "missing field y in A literal",
+		},
+		"unknown field": {
+			input:    "struct A { x: int32 }\nvar a = A { x: 1, z: 2 }",
+			expected: "struct A has no field z",
+		},
+		"field set twice": {
+			input:    "struct A { x: int32 }\nvar a = A { x: 1, x: 2 }",
+			expected: "field x already set",
+		},
+		"wrong field type": {
+			input:    "struct A { x: int32 }\nvar a = A { x: true }",
+			expected: "wrong type assignment",
+		},
+		"read unknown field": {
+			input:    "struct A { x: int32 }\nvar a = A { x: 1 }\nvar b = a.y",
+			expected: "struct A has no field y",
+		},
+		"field of non struct": {
+			input:    "var a = 1\nvar b = a.x",
+			expected: "type int32 has no fields",
+		},
+		"assign wrong field type": {
+			input:    "struct A { x: int32 }\nvar a = A { x: 1 }\na.x = 'c'",
+			expected: "wrong type assignment",
+		},
+		"mismatched structs": {
+			input:    "struct A { x: int32 }\nstruct B { x: int32 }\nvar a: B = A { x: 1 }",
+			expected: "wrong type assignment",
+		},
+		"struct arithmetic": {
+			input:    "struct A { x: int32 }\nvar a = A { x: 1 }\nvar b = a + a",
+			expected: "operator + does not support A operands",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_Arrays(t *testing.T) {
+	input := `const N = 3
+
+fn sum(values: []int32): int32 {
+	var total = 0
+	for i in 0..len(values) {
+		total += values[i]
+	}
+	return total
+}
+
+fn main(): int32 {
+	var a: [N]int32 = [1, 2, 3]
+	var flags = [true, false]
+	a[0] = 4
+	a[1] *= 2
+	var s = a[1..]
+	var all = a[..]
+	return sum(s) + all[2]
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 3)
+
+	sum := program.Statements[1].(*parser.FnStatement)
+	sliceType := sum.Args[0].Type
+	require.Equal(t, "[]int32", program.Types.Name(sliceType))
+	require.Equal(t, &parser.ArrayType{Elem: parser.Int32, Slice: true}, program.Types.Array(sliceType))
+
+	values := &parser.Identifier{Value: "values", Type: sliceType}
+	loop := sum.Body[1].(*parser.ForStatement)
+	require.Equal(t, &parser.LenExpression{Value: values}, loop.End)
+
+	total := &parser.Identifier{Value: "total", Type: parser.Int32}
+	require.Equal(t, &parser.ReassignVarStatement{
+		VarName: "total",
+		Type:    parser.Int32,
+		Value: &parser.InfixExpression{
+			Left:     total,
+			Operator: "+",
+			Right: &parser.IndexExpression{
+				Value:  values,
+				Index:  &parser.Identifier{Value: "i", Type: parser.Int32},
+				Type:   parser.Int32,
+				Line:   6,
+				Column: 17,
+			},
+		},
+	}, loop.Body[0])
+
+	body := program.Statements[2].(*parser.FnStatement).Body
+
+	// the length of the array type can be a constant
+	arrayVar := body[0].(*parser.VarStatement)
+	require.Equal(t, &parser.ArrayType{Elem: parser.Int32, Len: 3}, program.Types.Array(arrayVar.Type))
+	require.Equal(t, "[3]int32", program.Types.Name(arrayVar.Type))
+	require.Equal(t, &parser.ArrayLiteral{
+		Type: arrayVar.Type,
+		Elements: []parser.Expression{
+			&parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+			&parser.IntegerLiteral{Value: 2, Type: parser.Int32},
+			&parser.IntegerLiteral{Value: 3, Type: parser.Int32},
+		},
+	}, arrayVar.Value)
+
+	// literals without a type take the type of their first element
+	flags := body[1].(*parser.VarStatement)
+	require.Equal(t, "[2]bool", program.Types.Name(flags.Type))
+
+	a := &parser.Identifier{Value: "a", Type: arrayVar.Type}
+	require.Equal(t, &parser.AssignIndexStatement{
+		Target: &parser.IndexExpression{
+			Value:  a,
+			Index:  &parser.IntegerLiteral{Value: 0, Type: parser.Int32},
+			Type:   parser.Int32,
+			Line:   14,
+			Column: 2,
+		},
+		Value: &parser.IntegerLiteral{Value: 4, Type: parser.Int32},
+	}, body[2])
+
+	require.IsType(t, &parser.AssignIndexStatement{}, body[3])
+
+	require.Equal(t, &parser.VarStatement{
+		Name: "s",
+		Type: sliceType,
+		Value: &parser.SliceExpression{
+			Value:  a,
+			Low:    &parser.IntegerLiteral{Value: 1, Type: parser.Int32},
+			Type:   sliceType,
+			Line:   16,
+			Column: 10,
+		},
+	}, body[4])
+
+	all := body[5].(*parser.VarStatement).Value.(*parser.SliceExpression)
+	require.Nil(t, all.Low)
+	require.Nil(t, all.High)
+}
+
+func TestParser_ArrayErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"wrong length": {
+			input:    "var a: [3]int32 = [1, 2]",
+			expected: "[3]int32 literal needs 3 elements, got 2",
+		},
+		"wrong element type": {
+			input:    "var a: [2]int32 = [1, true]",
+			expected: "wrong type assignment",
+		},
+		"mixed elements": {
+			input:    "var a = [1, 'c']",
+			expected: "wrong type assignment",
+		},
+		"empty literal": {
+			input:    "var a = []",
+			expected: "cannot infer the type of an empty array literal",
+		},
+		"variable length": {
+			input:    "var n = 3\nvar a: [n]int32 = [1, 2, 3]",
+			expected: "array length must be a non negative integer constant, got n",
+		},
+		"length overflow": {
+			input:    "var a: [3000000000]int32",
+			expected: "array length 3000000000 overflows int32",
+		},
+		"constant index out of range": {
+			input:    "var a = [1, 2, 3]\nvar b = a[3]",
+			expected: "index 3 out of range for [3]int32",
+		},
+		"negative index": {
+			input:    "var a = [1, 2, 3]\nvar b = a[-1]",
+			expected: "invalid index -1, index must not be negative",
+		},
+		"slice bound out of range": {
+			input:    "var a = [1, 2, 3]\nvar b = a[1..4]",
+			expected: "index 4 out of range for [3]int32",
+		},
+		"inverted slice bounds": {
+			input:    "var a = [1, 2, 3]\nvar b = a[2..1]",
+			expected: "invalid slice bounds 2..1",
+		},
+		"float index": {
+			input:    "var a = [1, 2, 3]\nvar b = a[1.5]",
+			expected: "index must be an integer, got float32",
+		},
+		"index non array": {
+			input:    "var a = 1\nvar b = a[0]",
+			expected: "type int32 cannot be indexed",
+		},
+		"len of number": {
+			input:    "var a = 1\nvar b = len(a)",
+			expected: "len expects an array, a slice or a string, got int32",
+		},
+		"len redefined": {
+			input:    "fn len(): int32 { return 0 }",
+			expected: "len is a builtin function",
+		},
+		"array to slice": {
+			input:    "var a: []int32 = [1, 2, 3]",
+			expected: "wrong type assignment",
+		},
+		"assign sub-slice": {
+			input:    "var a = [1, 2, 3]\na[0..1] = a[1..2]",
+			expected: "cannot assign to a sub-slice",
+		},
+		"array arithmetic": {
+			input:    "var a = [1, 2]\nvar b = a + a",
+			expected: "operator + does not support [2]int32 operands",
+		},
+		"slice of a literal": {
+			input:    "var s = [1, 2, 3][0..2]",
+			expected: "cannot slice a temporary array, store it in a variable first",
+		},
+		"slice of a call result": {
+			input:    "fn pair(): [2]int32 {\n\treturn [1, 2]\n}\nvar s = pair()[0..]",
+			expected: "cannot slice a temporary array, store it in a variable first",
+		},
+		"returned slice of a local": {
+			input:    "fn f(): []int32 {\n\tvar a = [1, 2]\n\treturn a[0..]\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"returned slice of a parameter": {
+			input:    "fn f(a: [2]int32): []int32 {\n\treturn a[..1]\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"slice of a local in a returned literal": {
+			input:    "struct View { items: []int32 }\nfn f(): View {\n\tvar a = [1, 2]\n\treturn View { items: a[0..] }\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"returned variable holding a slice of a local": {
+			input:    "fn f(): []int32 {\n\tvar a = [1, 2]\n\tvar s = a[..]\n\treturn s\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"returned copy of a slice of a local": {
+			input:    "fn f(): []int32 {\n\tvar a = [1, 2]\n\tvar s: []int32\n\tif true {\n\t\ts = a[1..]\n\t}\n\tvar t = s[0..]\n\treturn t\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"struct holding a slice of a local": {
+			input:    "struct View { items: []int32 }\nfn f(): []int32 {\n\tvar a = [1, 2]\n\tvar v = View { items: a[0..] }\n\treturn v.items\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+		"slice of a local stored in a global": {
+			input:    "var s: []int32\nfn f() {\n\tvar a = [1, 2]\n\ts = a[0..]\n}",
+			expected: "slice of the local array a cannot outlive its function",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_Scopes(t *testing.T) {
+	input := `var limit = 10
+
+fn first(a: int32): int32 {
+	var x = a
+	if x > limit {
+		var x = true
+		if x {
+			return 1
+		}
+	}
+	return x
+}
+
+fn second(x: bool): bool {
+	var a = 2
+	return x
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+	require.NoError(t, err)
+	require.Len(t, program.Statements, 3)
+
+	first := program.Statements[1].(*parser.FnStatement)
+	ifStmt := first.Body[1].(*parser.IfStatement)
+	require.Equal(t, &parser.Identifier{Value: "limit", Type: parser.Int32}, ifStmt.Condition.(*parser.InfixExpression).Right)
+
+	// the shadowing variable is only visible inside its block
+	inner := ifStmt.Consequence[1].(*parser.IfStatement)
+	require.Equal(t, &parser.Identifier{Value: "x", Type: parser.Bool}, inner.Condition)
+	require.Equal(t, &parser.Identifier{Value: "x", Type: parser.Int32}, first.Body[2].(*parser.ReturnStatement).Value)
+
+	second := program.Statements[2].(*parser.FnStatement)
+	require.Equal(t, &parser.Identifier{Value: "x", Type: parser.Bool}, second.Body[1].(*parser.ReturnStatement).Value)
+}
+
+func TestParser_ScopeErrors(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"local does not leak into other functions": {
+			input:    "fn first() {\n\tvar a = 1\n}\n\nfn second() {\n\ta = 2\n}",
+			expected: "variable undefined: a",
+		},
+		"undefined variable in expression": {
+			input:    "fn main(): int32 {\n\treturn b + 1\n}",
+			expected: "variable undefined: b",
+		},
+		"block variable used after the block": {
+			input:    "fn main(): int32 {\n\tvar ok = true\n\tif ok {\n\t\tvar n = 1\n\t}\n\treturn n\n}",
+			expected: "variable undefined: n",
+		},
+		"redeclared in the same scope": {
+			input:    "fn main() {\n\tvar a = 1\n\tvar a = 2\n}",
+			expected: "variable a already declared in this scope",
+		},
+		"duplicate parameter": {
+			input:    "fn main(a: int32, a: int32) {\n}",
+			expected: "duplicate parameter a",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := parser.NewParser(l.NextToken())
+
+			_, err := p.ParseProgram()
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParser_ErrorRecovery(t *testing.T) {
+	input := `var a = 1 2
+var b = 3
+
+fn first(): int32 {
+	var c = 0
+	c = undefined + 1
+	var d: int32 = "text"
+	if c > 0 {
+		var e = )
+	}
+	return b
+}
+
+fn second() {
+	var f = 4
+	f = f +
+}
+
+var g = 5`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+
+	var errs parser.ErrList
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 5)
+
+	lines := make([]int, len(errs))
+	for idx, err := range errs {
+		lines[idx] = err.Line
+	}
+	require.Equal(t, []int{1, 6, 7, 9, 17}, lines)
+	require.ErrorIs(t, err, parser.ErrVariableUndefined)
+
+	// a missing operand is reported where the expression was expected
+	require.Equal(t, "Error at line 9, column 10: expected expression, got RPAREN", errs[3].Error())
+	require.Equal(t, "Error at line 17, column 0: expected expression, got RBRACE", errs[4].Error())
+
+	// the statements without errors are still available
+	require.NotNil(t, program)
+	require.Len(t, program.Statements, 4)
+
+	first := program.Statements[1].(*parser.FnStatement)
+	require.Equal(t, "first", first.Name)
+	require.Len(t, first.Body, 3)
+	require.Equal(t, "second", program.Statements[2].(*parser.FnStatement).Name)
+	require.Equal(t, "g", program.Statements[3].(*parser.VarStatement).Name)
+}
+
+func TestParser_ErrorRecoveryUnclosedBlock(t *testing.T) {
+	input := `fn first() {
+	var a = 1
+
+fn second() {
+	var b = 2
+}`
+
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := parser.NewParser(l.NextToken())
+
+	program, err := p.ParseProgram()
+
+	var errs parser.ErrList
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "expected: }, got: FN")
+
+	require.Len(t, program.Statements, 1)
+	require.Equal(t, "second", program.Statements[0].(*parser.FnStatement).Name)
+}
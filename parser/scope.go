@@ -0,0 +1,47 @@
+package parser
+
+// scope holds the variables declared in a block, lookups walk up the
+// enclosing scopes: blocks, the function parameters and the program.
+type scope struct {
+	vars  map[string]*VarStatement
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{
+		vars:  map[string]*VarStatement{},
+		outer: outer,
+	}
+}
+
+// lookup returns the innermost variable with the given name.
+func (s *scope) lookup(name string) (*VarStatement, bool) {
+	for current := s; current != nil; current = current.outer {
+		if stmt, ok := current.vars[name]; ok {
+			return stmt, true
+		}
+	}
+	return nil, false
+}
+
+// isGlobal reports whether the innermost variable with
+// the given name is declared in the top-level scope.
+func (s *scope) isGlobal(name string) bool {
+	for current := s; current != nil; current = current.outer {
+		if _, ok := current.vars[name]; ok {
+			return current.outer == nil
+		}
+	}
+	return false
+}
+
+// declare adds a variable to the scope, it shadows variables with the
+// same name from enclosing scopes but cannot redeclare one of its own.
+func (s *scope) declare(stmt *VarStatement) bool {
+	if _, exists := s.vars[stmt.Name]; exists {
+		return false
+	}
+
+	s.vars[stmt.Name] = stmt
+	return true
+}
@@ -0,0 +1,344 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/EclesioMeloJunior/lotus/lexer"
+)
+
+// StructStatement declares a struct type. Fields are laid out in
+// memory in declaration order, like the fields of a C struct.
+type StructStatement struct {
+	Name   string
+	Fields []*Field
+	Type   Type
+	Doc    string
+}
+
+// Field is a named member of a struct.
+type Field struct {
+	Name string
+	Type Type
+}
+
+// field returns the index of the field with the given name.
+func (s *StructStatement) field(name string) (int, bool) {
+	for idx, field := range s.Fields {
+		if field.Name == name {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// StructLiteral builds a struct value, Fields holds the
+// value of every field in declaration order.
+type StructLiteral struct {
+	Type   Type
+	Fields []Expression
+}
+
+func (*StructLiteral) expressionNode() {}
+
+// FieldAccess reads the field at Index of the struct Value, e.g: p.x
+type FieldAccess struct {
+	Value Expression
+	Field string
+	Index int
+	Type  Type
+}
+
+func (*FieldAccess) expressionNode() {}
+
+// AssignFieldStatement assigns Value to a field of a struct variable,
+// Target is a chain of field accesses starting at the variable.
+type AssignFieldStatement struct {
+	Target *FieldAccess
+	Value  Expression
+}
+
+// firstStructType is the Type of the first declared struct, every
+// struct declaration takes the next Type after it.
+const firstStructType Type = 1 << 10
+
+// declareStruct gives the struct its Type, the next one after
+// the structs declared before it.
+func (types *Types) declareStruct(stmt *StructStatement) {
+	stmt.Type = firstStructType + Type(len(types.structs))
+	types.structs = append(types.structs, stmt)
+}
+
+// Struct returns the declaration of the struct type t,
+// or nil if t is not a struct type.
+func (types *Types) Struct(t Type) *StructStatement {
+	if !t.IsStruct() {
+		return nil
+	}
+
+	idx := int(t - firstStructType)
+	if idx >= len(types.structs) {
+		return nil
+	}
+	return types.structs[idx]
+}
+
+// IsStruct reports whether t is a declared struct type.
+func (t Type) IsStruct() bool {
+	return t >= firstStructType && t < firstArrayType
+}
+
+// parseStructStatement parses a struct declaration, fields are
+// separated by commas or new lines:
+//
+//	struct Point { x: int32, y: int32 }
+func (p *Parser) parseStructStatement() (*StructStatement, error) {
+	stmt := &StructStatement{Doc: p.curToken.Doc}
+
+	if p.scope.outer != nil {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    errors.New("structs can only be declared at the top level"),
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.IDENT); err != nil {
+		return nil, err
+	}
+	stmt.Name = p.curToken.Literal
+	nameToken := p.curToken
+
+	if _, exists := p.structs[stmt.Name]; exists {
+		return nil, &ErrParser{
+			Line:   nameToken.Line,
+			Column: nameToken.Column,
+			Err:    fmt.Errorf("struct %s already declared", stmt.Name),
+		}
+	}
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.nextToken()
+		if p.curToken.Type == lexer.SEMICOLON {
+			continue
+		}
+
+		if p.curToken.Type == lexer.RBRACE {
+			break
+		}
+
+		if p.curToken.Type != lexer.IDENT {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("expected: field name, got: %s", p.curToken.Type.String()),
+			}
+		}
+
+		field := &Field{Name: p.curToken.Literal}
+		fieldToken := p.curToken
+		if _, exists := stmt.field(field.Name); exists {
+			return nil, &ErrParser{
+				Line:   fieldToken.Line,
+				Column: fieldToken.Column,
+				Err:    fmt.Errorf("duplicate field %s in struct %s", field.Name, stmt.Name),
+			}
+		}
+
+		if err := p.consumeOrFail(lexer.COLON); err != nil {
+			return nil, err
+		}
+
+		// a struct cannot contain itself, it would have an infinite size
+		if p.peekTokenIs(lexer.IDENT) && p.peekToken.Literal == stmt.Name {
+			return nil, &ErrParser{
+				Line:   p.peekToken.Line,
+				Column: p.peekToken.Column,
+				Err:    fmt.Errorf("struct %s cannot contain itself", stmt.Name),
+			}
+		}
+
+		var err error
+		if field.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		stmt.Fields = append(stmt.Fields, field)
+
+		switch {
+		case p.peekTokenIs(lexer.COMMA) || p.peekTokenIs(lexer.SEMICOLON):
+			p.nextToken()
+		case !p.peekTokenIs(lexer.RBRACE):
+			return nil, &ErrParser{
+				Line:   p.peekToken.Line,
+				Column: p.peekToken.Column,
+				Err:    fmt.Errorf("expected: , or }, got: %s", p.peekToken.Type.String()),
+			}
+		}
+	}
+
+	p.types.declareStruct(stmt)
+	p.structs[stmt.Name] = stmt
+	return stmt, nil
+}
+
+// parseType parses the type following the current token, either
+// a raw type, the name of a declared struct or an array type.
+func (p *Parser) parseType() (Type, error) {
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case lexer.RAWTYPE:
+		return getTypeFromLiteral(p.curToken.Literal), nil
+	case lexer.LBRACKET:
+		return p.parseArrayType()
+	case lexer.IDENT:
+		if stmt, ok := p.structs[p.curToken.Literal]; ok {
+			return stmt.Type, nil
+		}
+
+		return Void, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("unknown type %s", p.curToken.Literal),
+		}
+	default:
+		expected := lexer.RAWTYPE
+		return Void, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("expected: %s, got: %s", expected.String(), p.curToken.Type.String()),
+		}
+	}
+}
+
+// parseStructLiteral parses the value of every field of the struct,
+// e.g: Point { x: 1, y: 2 }. Fields can be given in any order but
+// none can be left out.
+func (p *Parser) parseStructLiteral(stmt *StructStatement) (Expression, error) {
+	literal := &StructLiteral{Type: stmt.Type, Fields: make([]Expression, len(stmt.Fields))}
+	line, column := p.curToken.Line, p.curToken.Column
+
+	if err := p.consumeOrFail(lexer.LBRACE); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.nextToken()
+		if p.curToken.Type == lexer.SEMICOLON {
+			continue
+		}
+
+		if p.curToken.Type == lexer.RBRACE {
+			break
+		}
+
+		if p.curToken.Type != lexer.IDENT {
+			return nil, &ErrParser{
+				Line:   p.curToken.Line,
+				Column: p.curToken.Column,
+				Err:    fmt.Errorf("expected: field name, got: %s", p.curToken.Type.String()),
+			}
+		}
+
+		fieldToken := p.curToken
+		idx, ok := stmt.field(fieldToken.Literal)
+		if !ok {
+			return nil, &ErrParser{
+				Line:   fieldToken.Line,
+				Column: fieldToken.Column,
+				Err:    fmt.Errorf("struct %s has no field %s", stmt.Name, fieldToken.Literal),
+			}
+		}
+
+		if literal.Fields[idx] != nil {
+			return nil, &ErrParser{
+				Line:   fieldToken.Line,
+				Column: fieldToken.Column,
+				Err:    fmt.Errorf("field %s already set", fieldToken.Literal),
+			}
+		}
+
+		if err := p.consumeOrFail(lexer.COLON); err != nil {
+			return nil, err
+		}
+
+		p.nextToken()
+		value, err := p.parseExpression(LOWEST, stmt.Fields[idx].Type)
+		if err != nil {
+			return nil, err
+		}
+		literal.Fields[idx] = value
+
+		switch {
+		case p.peekTokenIs(lexer.COMMA) || p.peekTokenIs(lexer.SEMICOLON):
+			p.nextToken()
+		case !p.peekTokenIs(lexer.RBRACE):
+			return nil, &ErrParser{
+				Line:   p.peekToken.Line,
+				Column: p.peekToken.Column,
+				Err:    fmt.Errorf("expected: , or }, got: %s", p.peekToken.Type.String()),
+			}
+		}
+	}
+
+	for idx, value := range literal.Fields {
+		if value == nil {
+			return nil, &ErrParser{
+				Line:   line,
+				Column: column,
+				Err:    fmt.Errorf("missing field %s in %s literal", stmt.Fields[idx].Name, stmt.Name),
+			}
+		}
+	}
+
+	return literal, nil
+}
+
+// parseFieldAccess parses the field name following a dot.
+func (p *Parser) parseFieldAccess(value Expression) (*FieldAccess, error) {
+	if err := p.consumeOrFail(lexer.IDENT); err != nil {
+		return nil, err
+	}
+
+	valueType := TypeOf(value)
+	stmt := p.types.Struct(valueType)
+	if stmt == nil {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("type %s has no fields", p.types.Name(valueType)),
+		}
+	}
+
+	idx, ok := stmt.field(p.curToken.Literal)
+	if !ok {
+		return nil, &ErrParser{
+			Line:   p.curToken.Line,
+			Column: p.curToken.Column,
+			Err:    fmt.Errorf("struct %s has no field %s", stmt.Name, p.curToken.Literal),
+		}
+	}
+
+	return &FieldAccess{
+		Value: value,
+		Field: p.curToken.Literal,
+		Index: idx,
+		Type:  stmt.Fields[idx].Type,
+	}, nil
+}
+
+// verifyStruct checks if st is a value of the struct type tt.
+func verifyStruct(st Expression, tt Type) error {
+	switch st.(type) {
+	case *Identifier, *FnCall, *StructLiteral, *FieldAccess, *IndexExpression:
+		if TypeOf(st) == tt {
+			return nil
+		}
+	}
+
+	return ErrWrongTypeAssigment
+}
@@ -3,7 +3,8 @@ package parser
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"math"
+	"unicode/utf8"
 )
 
 // Types defines behaviors for a certain instance/raw value.
@@ -12,19 +13,223 @@ var ErrWrongTypeAssigment = errors.New("wrong type assignment")
 
 type Type int
 
+// Types holds the struct types declared by a program and the array
+// and slice types it uses, a Type from firstStructType or from
+// firstArrayType is an index in one of them. Every parser fills its
+// own table, shared with the Program it returns.
+type Types struct {
+	structs  []*StructStatement
+	arrays   []ArrayType
+	arrayIDs map[ArrayType]Type
+}
+
+// Name returns the name of t, struct types are named after their
+// declaration and array types after their elements, e.g: [3]Point
+func (types *Types) Name(t Type) string {
+	if stmt := types.Struct(t); stmt != nil {
+		return stmt.Name
+	}
+
+	if array := types.Array(t); array != nil {
+		if array.Slice {
+			return "[]" + types.Name(array.Elem)
+		}
+		return fmt.Sprintf("[%d]%s", array.Len, types.Name(array.Elem))
+	}
+	return t.String()
+}
+
 const (
 	Void Type = iota
 	Int32
 	String
 	Float32
+	// Bool holds true or false, it is also the result
+	// of comparisons and logical operators
+	Bool
+	// Char is a unicode scalar value
+	Char
+	// fixed width integers, Int32 is declared above
+	Int8
+	Int16
+	Int64
+	Uint8
+	Uint16
+	Uint32
+	Uint64
+	// Isize and Usize are as wide as a pointer of the target
+	Isize
+	Usize
+	// Float64 is a double precision float, Float32 is declared above
+	Float64
+)
+
+// literalSuffixes maps numeric literal suffixes to their types, e.g: 10i32, 2.5f32
+var literalSuffixes = map[string]Type{
+	"i8": Int8, "i16": Int16, "i32": Int32, "i64": Int64, "isize": Isize,
+	"u8": Uint8, "u16": Uint16, "u32": Uint32, "u64": Uint64, "usize": Usize,
+	"f32": Float32, "f64": Float64,
+}
+
+func (t Type) String() string {
+	switch t {
+	case Void:
+		return "void"
+	case Int32:
+		return "int32"
+	case String:
+		return "string"
+	case Float32:
+		return "float32"
+	case Bool:
+		return "bool"
+	case Char:
+		return "char"
+	case Int8:
+		return "int8"
+	case Int16:
+		return "int16"
+	case Int64:
+		return "int64"
+	case Uint8:
+		return "uint8"
+	case Uint16:
+		return "uint16"
+	case Uint32:
+		return "uint32"
+	case Uint64:
+		return "uint64"
+	case Isize:
+		return "isize"
+	case Usize:
+		return "usize"
+	case Float64:
+		return "float64"
+	default:
+		// composite types are only named by the Types of their program
+		switch {
+		case t.IsStruct():
+			return "struct"
+		case t.isArrayOrSlice():
+			return "array"
+		default:
+			return "unknown"
+		}
+	}
+}
+
+// IsInteger reports whether t is a fixed width or pointer sized integer.
+func (t Type) IsInteger() bool {
+	switch t {
+	case Int8, Int16, Int32, Int64, Isize, Uint8, Uint16, Uint32, Uint64, Usize:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSigned reports whether t is a signed integer.
+func (t Type) IsSigned() bool {
+	switch t {
+	case Int8, Int16, Int32, Int64, Isize:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFloat reports whether t is a single or double precision float.
+func (t Type) IsFloat() bool {
+	return t == Float32 || t == Float64
+}
+
+func (t Type) isNumeric() bool {
+	return t.IsInteger() || t.IsFloat()
+}
+
+// bitSize returns the size in bits of numeric types, the parser does
+// not know the target so pointer sized integers are taken as 64 bits
+func (t Type) bitSize() int {
+	switch t {
+	case Int8, Uint8:
+		return 8
+	case Int16, Uint16:
+		return 16
+	case Int32, Uint32, Float32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+// canWiden reports whether a number of type from implicitly converts
+// to the type to, that is when every value of from is a value of to:
+// to is as wide with the same signedness, or from is unsigned and to is
+// a wider signed integer. Pointer sized integers are at least 32 and at
+// most 64 bits wide. Float32 widens to Float64, integers and floats
+// never convert implicitly.
+func canWiden(from, to Type) bool {
+	if from.IsFloat() && to.IsFloat() {
+		return from.bitSize() <= to.bitSize()
+	}
+
+	if !from.IsInteger() || !to.IsInteger() {
+		return false
+	}
+
+	if from == to {
+		return true
+	}
+
+	fromBits, toBits := from.bitSize(), to.bitSize()
+	if to == Isize || to == Usize {
+		toBits = 32
+	}
+
+	switch {
+	case from.IsSigned() == to.IsSigned():
+		return fromBits <= toBits
+	case !from.IsSigned():
+		return fromBits < toBits
+	default:
+		return false
+	}
+}
+
+var (
+	arithmeticOperators = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true}
+	bitwiseOperators    = map[string]bool{"&": true, "|": true, "^": true, "<<": true, ">>": true}
+	logicalOperators    = map[string]bool{"&&": true, "||": true}
+	orderingOperators   = map[string]bool{"<": true, "<=": true, ">": true, ">=": true}
+	comparisonOperators = map[string]bool{
+		"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	}
 )
 
 func (t *Type) Verify(st Expression) error {
+	if t.IsInteger() {
+		return verifyInteger(st, *t)
+	}
+
+	if t.IsFloat() {
+		return verifyFloat(st, *t)
+	}
+
+	if t.IsStruct() {
+		return verifyStruct(st, *t)
+	}
+
+	if t.isArrayOrSlice() {
+		return verifyArray(st, *t)
+	}
+
 	switch *t {
-	case Int32:
-		return verifyInt32(st)
 	case String:
 		return verifyString(st)
+	case Bool:
+		return verifyBool(st)
+	case Char:
+		return verifyChar(st)
 	case Void:
 		return nil
 	default:
@@ -32,40 +237,105 @@ func (t *Type) Verify(st Expression) error {
 	}
 }
 
-func verifyInt32(st Expression) error {
+// verifyInteger checks if st is an integer expression of type tt,
+// narrower integers are accepted as they are widened to tt.
+func verifyInteger(st Expression, tt Type) error {
 	switch inner := st.(type) {
 	case *Identifier:
-		if inner.Type == Int32 {
+		if canWiden(inner.Type, tt) {
 			return nil
 		}
 	case *IntegerLiteral:
-		return nil
+		if inner.Type == Void || canWiden(inner.Type, tt) {
+			return nil
+		}
 	case *InfixExpression:
-		if err := verifyInt32(inner.Left); err != nil {
+		if err := verifyInteger(inner.Left, tt); err != nil {
 			return err
 		}
 
-		if !strings.ContainsAny(inner.Operator, "+-*/") {
-			return fmt.Errorf("int32 allowed infix operators: + - * /")
+		if !arithmeticOperators[inner.Operator] && !bitwiseOperators[inner.Operator] {
+			return fmt.Errorf("%s allowed infix operators: + - * / %% & | ^ << >>", tt)
 		}
 
-		if err := verifyInt32(inner.Right); err != nil {
+		if err := verifyInteger(inner.Right, tt); err != nil {
 			return err
 		}
 
 		return nil
 	case *PrefixExpression:
-		if inner.Operator == "~" || inner.Operator == "++" || inner.Operator == "--" {
-			return fmt.Errorf("int32 allowed prefix operators: ~ ++ --")
+		if inner.Operator != "-" && inner.Operator != "~" {
+			return fmt.Errorf("%s allowed prefix operators: - ~", tt)
+		}
+
+		if inner.Operator == "-" && !tt.IsSigned() {
+			return fmt.Errorf("%s values cannot be negated", tt)
+		}
+
+		if err := verifyInteger(inner.Right, tt); err != nil {
+			return err
+		}
+
+		return nil
+	case *FnCall:
+		if canWiden(inner.Type, tt) {
+			return nil
+		}
+	case *FieldAccess, *IndexExpression, *LenExpression:
+		if canWiden(TypeOf(inner), tt) {
+			return nil
+		}
+	case *CastExpression:
+		if canWiden(inner.Type, tt) {
+			return nil
+		}
+	}
+
+	return ErrWrongTypeAssigment
+}
+
+// verifyFloat checks if st is a float expression of type tt, float32
+// values are accepted by float64 while integers need a conversion.
+func verifyFloat(st Expression, tt Type) error {
+	switch inner := st.(type) {
+	case *Identifier:
+		if canWiden(inner.Type, tt) {
+			return nil
+		}
+	case *FloatLiteral:
+		if inner.Type == Void || canWiden(inner.Type, tt) {
+			return nil
+		}
+	case *InfixExpression:
+		if err := verifyFloat(inner.Left, tt); err != nil {
+			return err
 		}
 
-		if err := verifyInt32(inner.Right); err != nil {
+		if !arithmeticOperators[inner.Operator] || inner.Operator == "%" {
+			return fmt.Errorf("%s allowed infix operators: + - * /", tt)
+		}
+
+		if err := verifyFloat(inner.Right, tt); err != nil {
 			return err
 		}
 
 		return nil
+	case *PrefixExpression:
+		if inner.Operator != "-" {
+			return fmt.Errorf("%s allowed prefix operators: -", tt)
+		}
+
+		return verifyFloat(inner.Right, tt)
 	case *FnCall:
-		if inner.Type == Int32 {
+		if canWiden(inner.Type, tt) {
+			return nil
+		}
+	case *FieldAccess, *IndexExpression, *LenExpression:
+		if canWiden(TypeOf(inner), tt) {
+			return nil
+		}
+	case *CastExpression:
+		if canWiden(inner.Type, tt) {
 			return nil
 		}
 	}
@@ -99,7 +369,275 @@ func verifyString(st Expression) error {
 		if inner.Type == String {
 			return nil
 		}
+	case *FieldAccess, *IndexExpression:
+		if TypeOf(inner) == String {
+			return nil
+		}
+	}
+
+	return ErrWrongTypeAssigment
+}
+
+func verifyBool(st Expression) error {
+	switch inner := st.(type) {
+	case *Identifier:
+		if inner.Type == Bool {
+			return nil
+		}
+	case *BooleanLiteral:
+		return nil
+	case *InfixExpression:
+		switch {
+		case logicalOperators[inner.Operator]:
+			if err := verifyBool(inner.Left); err != nil {
+				return err
+			}
+
+			return verifyBool(inner.Right)
+		case comparisonOperators[inner.Operator]:
+			return verifyComparison(inner)
+		default:
+			return fmt.Errorf("bool allowed infix operators: == != < <= > >= && ||")
+		}
+	case *PrefixExpression:
+		if inner.Operator != "!" {
+			return fmt.Errorf("bool allowed prefix operators: !")
+		}
+
+		return verifyBool(inner.Right)
+	case *FnCall:
+		if inner.Type == Bool {
+			return nil
+		}
+	case *FieldAccess, *IndexExpression:
+		if TypeOf(inner) == Bool {
+			return nil
+		}
 	}
 
 	return ErrWrongTypeAssigment
 }
+
+// verifyCompoundAssignment checks if a variable of type t supports
+// the arithmetic operator of a compound assignment or increment.
+func verifyCompoundAssignment(operator string, t Type) error {
+	switch {
+	case !t.IsInteger() && !t.IsFloat():
+		return fmt.Errorf("%s variables are not numeric", t)
+	case operator == "%" && !t.IsInteger():
+		return fmt.Errorf("%s variables are not integers", t)
+	}
+
+	return nil
+}
+
+// verifyComparison checks if both sides of a comparison
+// have the same type and if that type supports the operator
+func verifyComparison(inner *InfixExpression) error {
+	_, err := comparisonType(inner)
+	return err
+}
+
+// comparisonType returns the type both operands of a comparison are
+// converted to: the type of the typed operand when the other is an
+// untyped literal, otherwise the wider of both types.
+func comparisonType(inner *InfixExpression) (Type, error) {
+	left, right := TypeOf(inner.Left), TypeOf(inner.Right)
+
+	var tt Type
+	switch {
+	case left == Void && right == Void:
+		tt = Int32
+	case left == Void || canWiden(left, right):
+		tt = right
+	default:
+		tt = left
+	}
+
+	comparable := tt.IsInteger() || tt.IsFloat() || tt == Char || (tt == Bool && !orderingOperators[inner.Operator])
+	if !comparable || tt.Verify(inner.Left) != nil || tt.Verify(inner.Right) != nil {
+		return Void, fmt.Errorf("operator %s: mismatched or not comparable operands", inner.Operator)
+	}
+
+	return tt, nil
+}
+
+// TypeOf returns the type of a verified expression. Integer literals
+// without a type, and expressions made only of them, are Void as they
+// take the type expected by their context. Float literals without a
+// type are Float32, which widens to any float type.
+func TypeOf(exp Expression) Type {
+	switch exp := exp.(type) {
+	case *IntegerLiteral:
+		return exp.Type
+	case *FloatLiteral:
+		if exp.Type == Void {
+			return Float32
+		}
+		return exp.Type
+	case *StringLiteral:
+		return String
+	case *CharLiteral:
+		return Char
+	case *BooleanLiteral:
+		return Bool
+	case *Identifier:
+		return exp.Type
+	case *FnCall:
+		return exp.Type
+	case *CastExpression:
+		return exp.Type
+	case *StructLiteral:
+		return exp.Type
+	case *FieldAccess:
+		return exp.Type
+	case *ArrayLiteral:
+		return exp.Type
+	case *IndexExpression:
+		return exp.Type
+	case *SliceExpression:
+		return exp.Type
+	case *LenExpression:
+		return Int32
+	case *PrefixExpression:
+		if exp.Operator == "!" {
+			return Bool
+		}
+		return TypeOf(exp.Right)
+	case *InfixExpression:
+		if comparisonOperators[exp.Operator] || logicalOperators[exp.Operator] {
+			return Bool
+		}
+
+		left, right := TypeOf(exp.Left), TypeOf(exp.Right)
+		if left == Void || canWiden(left, right) {
+			return right
+		}
+		return left
+	default:
+		return Void
+	}
+}
+
+// convertExpression makes the implicit conversions of an expression
+// verified against tt explicit: numeric literals without a type take
+// the type tt, failing if they do not fit, and narrower numbers are
+// widened by a cast.
+func convertExpression(exp Expression, tt Type) (Expression, error) {
+	switch inner := exp.(type) {
+	case *IntegerLiteral:
+		if !tt.IsInteger() || inner.Type == tt {
+			return exp, nil
+		}
+
+		if inner.Type == Void && !fitsInteger(inner.Value, tt) {
+			return nil, fmt.Errorf("integer literal %d overflows %s", inner.Value, tt)
+		}
+		return &IntegerLiteral{Value: inner.Value, Type: tt}, nil
+	case *FloatLiteral:
+		if !tt.IsFloat() || inner.Type == tt {
+			return exp, nil
+		}
+
+		if tt == Float32 && math.Abs(inner.Value) > math.MaxFloat32 {
+			return nil, fmt.Errorf("float literal %g overflows %s", inner.Value, tt)
+		}
+		return &FloatLiteral{Value: inner.Value, Type: tt}, nil
+	case *Identifier, *FnCall, *CastExpression, *FieldAccess, *IndexExpression, *LenExpression:
+		if from := TypeOf(exp); from != tt && canWiden(from, tt) {
+			return &CastExpression{Value: exp, From: from, Type: tt}, nil
+		}
+		return exp, nil
+	case *PrefixExpression:
+		operandType := tt
+		if inner.Operator == "!" {
+			operandType = Bool
+		}
+
+		right, err := convertExpression(inner.Right, operandType)
+		if err != nil {
+			return nil, err
+		}
+		return &PrefixExpression{Operator: inner.Operator, Right: right}, nil
+	case *InfixExpression:
+		operandType := tt
+		switch {
+		case logicalOperators[inner.Operator]:
+			operandType = Bool
+		case comparisonOperators[inner.Operator]:
+			var err error
+			if operandType, err = comparisonType(inner); err != nil {
+				return nil, err
+			}
+		}
+
+		left, err := convertExpression(inner.Left, operandType)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := convertExpression(inner.Right, operandType)
+		if err != nil {
+			return nil, err
+		}
+		return &InfixExpression{Left: left, Operator: inner.Operator, Right: right}, nil
+	default:
+		return exp, nil
+	}
+}
+
+func verifyChar(st Expression) error {
+	switch inner := st.(type) {
+	case *Identifier:
+		if inner.Type == Char {
+			return nil
+		}
+	case *CharLiteral:
+		return nil
+	case *FnCall:
+		if inner.Type == Char {
+			return nil
+		}
+	case *FieldAccess, *IndexExpression:
+		if TypeOf(inner) == Char {
+			return nil
+		}
+	case *CastExpression:
+		if inner.Type == Char {
+			return nil
+		}
+	}
+
+	return ErrWrongTypeAssigment
+}
+
+// verifyConversion checks if the cast source type can be converted to
+// the target type, integers convert between each other, wrapping when
+// the target is narrower, numbers convert between integers and floats,
+// and char converts from and to integers. Only conversions between
+// numbers can be checked.
+func (p *Parser) verifyConversion(cast *CastExpression) error {
+	if cast.Checked && (!cast.From.isNumeric() || !cast.Type.isNumeric()) {
+		return fmt.Errorf("cannot check conversion of %s to %s, only numbers are checked", p.types.Name(cast.From), cast.Type)
+	}
+
+	if cast.From == cast.Type {
+		return nil
+	}
+
+	switch {
+	case cast.From.isNumeric() && cast.Type.isNumeric():
+		return nil
+	case cast.From == Char && cast.Type.IsInteger():
+		return nil
+	case cast.From.IsInteger() && cast.Type == Char:
+		// constants are checked at compile time, runtime values are
+		// reinterpreted as is
+		if literal, ok := cast.Value.(*IntegerLiteral); ok && !utf8.ValidRune(rune(literal.Value)) {
+			return fmt.Errorf("%d is not a valid unicode scalar value", literal.Value)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", p.types.Name(cast.From), cast.Type)
+}